@@ -0,0 +1,101 @@
+// Comando dlq inspeciona e reenvia as mensagens das filas de mensagens mortas
+// (DLQ) dos tópicos Kafka.
+//
+// Uso:
+//
+//	dlq list -topic transactions [-limit 50]
+//	dlq redrive -topic transactions -partition 0 -offset 12
+//	dlq redrive -topic transactions -all
+//
+// A conexão usa as mesmas variáveis KAFKA_* da API: KAFKA_BROKERS, separados
+// por vírgula (padrão kafka:9092), KAFKA_CLIENT_ID, KAFKA_TLS_* e
+// KAFKA_SASL_*.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/red-velvet-workspace/banco-digital/internal/infrastructure/kafka"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	flags := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	topic := flags.String("topic", "", "tópico original (a DLQ é <tópico>.dlq)")
+	limit := flags.Int("limit", 50, "máximo de mensagens listadas por partição (0 para todas)")
+	partition := flags.Int("partition", -1, "partição da mensagem a reenviar")
+	offset := flags.Int64("offset", -1, "offset da mensagem a reenviar")
+	all := flags.Bool("all", false, "reenvia todas as mensagens da DLQ")
+	flags.Parse(os.Args[2:])
+
+	if *topic == "" {
+		usage()
+	}
+
+	client, err := kafka.LoadClientConfig()
+	if err != nil {
+		log.Fatalf("[ERROR] Configuração do Kafka inválida: %v", err)
+	}
+	queue, err := kafka.OpenDeadLetterQueue(client, *topic)
+	if err != nil {
+		log.Fatalf("[ERROR] Erro ao conectar ao Kafka: %v", err)
+	}
+	defer queue.Close()
+
+	switch os.Args[1] {
+	case "list":
+		letters, err := queue.List(*limit)
+		if err != nil {
+			log.Fatalf("[ERROR] Erro ao ler a DLQ: %v", err)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		for _, letter := range letters {
+			enc.Encode(letter)
+		}
+		log.Printf("[INFO] %d mensagem(ns) em %s", len(letters), kafka.DeadLetterTopic(*topic))
+
+	case "redrive":
+		var letters []kafka.DeadLetter
+		switch {
+		case *all:
+			letters, err = queue.List(0)
+		case *partition >= 0 && *offset >= 0:
+			var letter *kafka.DeadLetter
+			letter, err = queue.Get(int32(*partition), *offset)
+			if err == nil {
+				letters = append(letters, *letter)
+			}
+		default:
+			usage()
+		}
+		if err != nil {
+			log.Fatalf("[ERROR] Erro ao ler a DLQ: %v", err)
+		}
+
+		for _, letter := range letters {
+			if err := queue.Redrive(letter); err != nil {
+				log.Fatalf("[ERROR] Erro ao reenviar a mensagem %d/%d: %v", letter.Partition, letter.Offset, err)
+			}
+			log.Printf("[INFO] Mensagem %d/%d reenviada para %s", letter.Partition, letter.Offset, *topic)
+		}
+		log.Printf("[INFO] %d mensagem(ns) reenviada(s)", len(letters))
+
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Uso:
+  dlq list -topic <tópico> [-limit N]
+  dlq redrive -topic <tópico> -partition P -offset O
+  dlq redrive -topic <tópico> -all`)
+	os.Exit(2)
+}
@@ -1,20 +1,27 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/red-velvet-workspace/banco-digital/internal/audit"
+	"github.com/red-velvet-workspace/banco-digital/internal/auth"
 	"github.com/red-velvet-workspace/banco-digital/internal/domain/models"
 	"github.com/red-velvet-workspace/banco-digital/internal/infrastructure/database"
 	"github.com/red-velvet-workspace/banco-digital/internal/infrastructure/kafka"
+	"github.com/red-velvet-workspace/banco-digital/internal/lifecycle"
 	"github.com/red-velvet-workspace/banco-digital/internal/middleware"
+	"github.com/red-velvet-workspace/banco-digital/internal/ratelimit"
 	"github.com/red-velvet-workspace/banco-digital/internal/services"
 )
 
@@ -34,62 +41,126 @@ func main() {
 		log.Fatalf("Failed to initialize database schema: %v", err)
 	}
 
-	// Configurar brokers Kafka
-	kafkaBrokers := []string{"kafka:9092"}
+	// Encerramento ordenado: as etapas registradas abaixo rodam na ordem
+	// inversa, dentro de SHUTDOWN_TIMEOUT
+	shutdownTimeout, err := lifecycle.LoadShutdownTimeout()
+	if err != nil {
+		log.Fatalf("Failed to load shutdown configuration: %v", err)
+	}
+	app := lifecycle.New(shutdownTimeout)
+	app.OnStop("banco de dados", func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.Close()
+	})
+
+	// Configurar verificação dos tokens JWT
+	jwtConfig, err := middleware.LoadJWTConfig()
+	if err != nil {
+		log.Fatalf("Failed to load JWT configuration: %v", err)
+	}
+	if err := middleware.InitJWT(jwtConfig); err != nil {
+		log.Fatalf("Failed to initialize JWT verification: %v", err)
+	}
+
+	// Configurar a conexão com o Kafka
+	kafkaClient, err := kafka.LoadClientConfig()
+	if err != nil {
+		log.Fatalf("Failed to load Kafka client configuration: %v", err)
+	}
+	consumerConfig, err := kafka.LoadConsumerConfig()
+	if err != nil {
+		log.Fatalf("Failed to load Kafka consumer configuration: %v", err)
+	}
 
-	// Inicializar produtor Kafka
-	producer, err := kafka.NewProducer(kafkaBrokers)
+	codecs, err := kafka.LoadCodecs()
 	if err != nil {
-		log.Fatalf("Failed to create Kafka producer: %v", err)
+		log.Fatalf("Failed to load Kafka codec configuration: %v", err)
 	}
-	defer producer.Close()
+
+	// Inicializar o broker: Kafka ou, com MESSAGE_BROKER=memory, o broker em
+	// memória do próprio processo
+	messageBroker := os.Getenv("MESSAGE_BROKER")
+	if messageBroker != kafka.BrokerMemory {
+		// Criar os tópicos que faltam e recusar os que divergem do declarado
+		topics, err := kafka.LoadTopics()
+		if err != nil {
+			log.Fatalf("Failed to load Kafka topic configuration: %v", err)
+		}
+		if err := kafka.EnsureTopics(kafkaClient, topics); err != nil {
+			log.Fatalf("Failed to provision Kafka topics: %v", err)
+		}
+	}
+	producer, subscriber, err := kafka.NewBroker(messageBroker, kafkaClient, consumerConfig, codecs)
+	if err != nil {
+		log.Fatalf("Failed to create message broker: %v", err)
+	}
+	app.OnStop("produtor Kafka", func(ctx context.Context) error {
+		return producer.Close()
+	})
 
 	// Inicializar serviços
-	accountService, err := services.NewAccountService(db, producer)
+	pinPolicy, err := services.LoadPINPolicy()
+	if err != nil {
+		log.Fatalf("Failed to load transaction PIN policy: %v", err)
+	}
+	accountService, err := services.NewAccountService(db, producer, pinPolicy)
 	if err != nil {
 		log.Fatalf("Failed to create account service: %v", err)
 	}
 
-	// Inicializar consumidores Kafka
-	consumer, err := kafka.NewConsumer(kafkaBrokers)
+	// Emissão de tokens para login de usuários
+	tokenIssuer, err := auth.NewTokenIssuer(jwtConfig.HMACSecret, jwtConfig.Issuer, jwtConfig.Audience, jwtConfig.AccessTokenTTL)
 	if err != nil {
-		log.Fatalf("Failed to create Kafka consumer: %v", err)
+		log.Fatalf("Failed to create token issuer: %v", err)
 	}
-	defer consumer.Close()
+	totpBox, err := auth.LoadSecretBox()
+	if err != nil {
+		log.Fatalf("Failed to load TOTP encryption key: %v", err)
+	}
+	// Sessões encerradas invalidam os access tokens já emitidos
+	sessionService, err := services.NewSessionService(db, jwtConfig.AccessTokenTTL)
+	if err != nil {
+		log.Fatalf("Failed to create session service: %v", err)
+	}
+	middleware.InitSessions(sessionService)
+	app.Go("sincronização de sessões", func(ctx context.Context) error {
+		sessionService.IniciarSincronizacao(ctx, 5*time.Second)
+		return nil
+	})
 
-	// Criar canal para sinais de término
-	done := make(chan bool)
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	authService, err := services.NewAuthService(db, tokenIssuer, jwtConfig.RefreshTokenTTL, totpBox, sessionService)
+	if err != nil {
+		log.Fatalf("Failed to create auth service: %v", err)
+	}
 
-	// Iniciar consumidores em goroutines
-	go func() {
-		if err := consumer.ConsumeAccounts(); err != nil {
-			log.Printf("Error consuming accounts: %v", err)
-			done <- true
-		}
-	}()
+	// Chaves de API de parceiros e ferramentas internas
+	apiKeyService, err := services.NewAPIKeyService(db)
+	if err != nil {
+		log.Fatalf("Failed to create API key service: %v", err)
+	}
+	middleware.InitAPIKeys(apiKeyService)
 
-	go func() {
-		if err := consumer.ConsumePIXKeys(); err != nil {
-			log.Printf("Error consuming PIX keys: %v", err)
-			done <- true
-		}
-	}()
+	// Inicializar consumidores Kafka
+	consumer, err := kafka.NewConsumer(db, subscriber, producer, consumerConfig)
+	if err != nil {
+		log.Fatalf("Failed to create Kafka consumer: %v", err)
+	}
+	app.OnStop("assinaturas Kafka", func(ctx context.Context) error {
+		return consumer.Close()
+	})
 
-	go func() {
-		if err := consumer.ConsumeCreditCards(); err != nil {
-			log.Printf("Error consuming credit cards: %v", err)
-			done <- true
-		}
-	}()
+	// Iniciar consumidores; no encerramento cada um termina a mensagem em
+	// andamento antes de parar
+	app.Go("consumidores Kafka", consumer.Run)
 
-	go func() {
-		if err := consumer.ConsumeTransactions(); err != nil {
-			log.Printf("Error consuming transactions: %v", err)
-			done <- true
-		}
-	}()
+	// Liberar pré-autorizações de cartão vencidas
+	app.Go("expiração de pré-autorizações", func(ctx context.Context) error {
+		accountService.IniciarExpiracaoAutorizacoes(ctx, time.Minute)
+		return nil
+	})
 
 	// Configurar rotas HTTP usando gorilla/mux
 	router := mux.NewRouter()
@@ -107,23 +178,253 @@ func main() {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-			
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Transaction-PIN, X-Request-ID, X-Device-ID, X-Device-Name, X-API-Key")
+
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(http.StatusOK)
 				return
 			}
-			
+
 			next.ServeHTTP(w, r)
 		})
 	})
 
+	// Limitar requisições por IP e por usuário
+	rateLimitConfig, err := ratelimit.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load rate limit configuration: %v", err)
+	}
+
+	// Identificar a requisição para o log de auditoria
+	router.Use(middleware.RequestContext(rateLimitConfig.TrustedProxy))
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if rateLimitConfig.Store == "postgres" {
+		rateLimitStore = ratelimit.NewPostgresStore(db)
+	}
+	router.Use(middleware.RateLimit(rateLimitStore, rateLimitConfig))
+
 	// Rota de status
 	statusRouter := router.PathPrefix("/status").Subrouter()
 	statusRouter.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
 
+	// Rotas de autenticação
+	authRouter := router.PathPrefix("/auth").Subrouter()
+	log.Printf("[DEBUG] Registrando rotas de autenticação...")
+
+	authRouter.Methods("POST").Path("/register").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Nome  string `json:"nome"`
+			Email string `json:"email"`
+			Senha string `json:"senha"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if req.Email == "" || req.Senha == "" {
+			http.Error(w, "E-mail e senha são obrigatórios", http.StatusBadRequest)
+			return
+		}
+
+		resp, err := authService.Registrar(r.Context(), req.Nome, req.Email, req.Senha)
+		if err != nil {
+			log.Printf("[ERROR] Erro ao cadastrar usuário: %v", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	authRouter.Methods("POST").Path("/login").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Email  string `json:"email"`
+			Senha  string `json:"senha"`
+			Codigo string `json:"codigo"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if req.Email == "" || req.Senha == "" {
+			http.Error(w, "E-mail e senha são obrigatórios", http.StatusBadRequest)
+			return
+		}
+
+		resp, err := authService.Login(r.Context(), req.Email, req.Senha, req.Codigo, dispositivo(r))
+		if err != nil {
+			log.Printf("[INFO] Login recusado para %s: %v", req.Email, err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	// Renovação e logout recebem o refresh token no corpo
+	refreshHandler := func(logout bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				RefreshToken string `json:"refresh_token"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+				return
+			}
+
+			if req.RefreshToken == "" {
+				http.Error(w, "Refresh token é obrigatório", http.StatusBadRequest)
+				return
+			}
+
+			var resp *models.APIResponse
+			var err error
+			if logout {
+				resp, err = authService.Logout(r.Context(), req.RefreshToken)
+			} else {
+				resp, err = authService.RenovarToken(r.Context(), req.RefreshToken)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), errorStatus(err))
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(resp)
+		}
+	}
+	authRouter.Methods("POST").Path("/refresh").HandlerFunc(refreshHandler(false))
+	authRouter.Methods("POST").Path("/logout").HandlerFunc(refreshHandler(true))
+
+	// Verificação em duas etapas (TOTP)
+	authRouter.Methods("POST").Path("/2fa/cadastrar").HandlerFunc(middleware.JWTMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := authService.CadastrarDoisFatores(r.Context())
+		if err != nil {
+			log.Printf("[ERROR] Erro ao cadastrar verificação em duas etapas: %v", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+
+	authRouter.Methods("POST").Path("/2fa/ativar").HandlerFunc(middleware.JWTMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Codigo string `json:"codigo"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if req.Codigo == "" {
+			http.Error(w, "Código é obrigatório", http.StatusBadRequest)
+			return
+		}
+
+		resp, err := authService.AtivarDoisFatores(r.Context(), req.Codigo, dispositivo(r))
+		if err != nil {
+			log.Printf("[INFO] Ativação da verificação em duas etapas recusada: %v", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+
+	authRouter.Methods("POST").Path("/2fa/desativar").HandlerFunc(middleware.JWTMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Senha  string `json:"senha"`
+			Codigo string `json:"codigo"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if req.Senha == "" || req.Codigo == "" {
+			http.Error(w, "Senha e código são obrigatórios", http.StatusBadRequest)
+			return
+		}
+
+		resp, err := authService.DesativarDoisFatores(r.Context(), req.Senha, req.Codigo)
+		if err != nil {
+			log.Printf("[INFO] Desativação da verificação em duas etapas recusada: %v", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+
+	// Sessões do usuário; encerrar uma sessão invalida os tokens dela
+	authRouter.Methods("GET").Path("/sessoes").HandlerFunc(middleware.JWTMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := sessionService.ListarSessoes(r.Context())
+		if err != nil {
+			log.Printf("[ERROR] Erro ao listar sessões: %v", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+
+	authRouter.Methods("DELETE").Path("/sessoes/{id}").HandlerFunc(middleware.JWTMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		sessionID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "ID da sessão inválido", http.StatusBadRequest)
+			return
+		}
+
+		resp, err := sessionService.EncerrarSessao(r.Context(), sessionID)
+		if err != nil {
+			log.Printf("[ERROR] Erro ao encerrar sessão: %v", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+
+		log.Printf("[INFO] Sessão %s encerrada", sessionID)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+
+	// Cadastro e redefinição do PIN de transação; redefinir desbloqueia
+	authRouter.Methods("POST").Path("/pin").HandlerFunc(middleware.JWTMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Senha string `json:"senha"`
+			PIN   string `json:"pin"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if req.Senha == "" || req.PIN == "" {
+			http.Error(w, "Senha e PIN são obrigatórios", http.StatusBadRequest)
+			return
+		}
+
+		resp, err := authService.DefinirPIN(r.Context(), req.Senha, req.PIN)
+		if err != nil {
+			log.Printf("[INFO] Definição de PIN recusada: %v", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+
 	// Rotas de Conta
 	contaRouter := router.PathPrefix("/conta").Subrouter()
 	log.Printf("[DEBUG] Registrando rotas de conta...")
@@ -149,7 +450,7 @@ func main() {
 
 		account, err := accountService.CriarConta(r.Context(), accountType)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 
@@ -159,7 +460,7 @@ func main() {
 
 	// Rota específica para estado
 	log.Printf("[DEBUG] Registrando rota PUT /conta/status")
-	contaRouter.Methods("PUT").Path("/status").HandlerFunc(middleware.JWTMiddleware(func(w http.ResponseWriter, r *http.Request) {
+	contaRouter.Methods("PUT").Path("/status").HandlerFunc(middleware.RequirePermission(auth.PermAccountsManage, func(w http.ResponseWriter, r *http.Request) {
 		log.Printf("[DEBUG] Recebida requisição PUT /conta/status")
 
 		var req struct {
@@ -194,7 +495,7 @@ func main() {
 		})
 		if err != nil {
 			log.Printf("[ERROR] Erro ao atualizar status da conta: %v", err)
-			http.Error(w, fmt.Sprintf("Failed to update account status: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Failed to update account status: %v", err), errorStatus(err))
 			return
 		}
 
@@ -204,7 +505,7 @@ func main() {
 		json.NewEncoder(w).Encode(account)
 	})).Methods("PUT")
 
-	contaRouter.HandleFunc("/cheque-especial", middleware.JWTMiddleware(func(w http.ResponseWriter, r *http.Request) {
+	contaRouter.HandleFunc("/cheque-especial", middleware.RequirePermission(auth.PermLimitsOverride, func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
 			return
@@ -232,7 +533,7 @@ func main() {
 
 		account, err := accountService.ConfigurarChequeEspecial(r.Context(), accountID, req.Limite)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 
@@ -242,7 +543,7 @@ func main() {
 
 	// Rota específica para notificações
 	log.Printf("[DEBUG] Registrando rota GET /conta/notificacoes")
-	contaRouter.Methods("GET").Path("/notificacoes").HandlerFunc(middleware.JWTMiddleware(func(w http.ResponseWriter, r *http.Request) {
+	contaRouter.Methods("GET").Path("/notificacoes").HandlerFunc(middleware.RequireScope(auth.PermStatementsRead, func(w http.ResponseWriter, r *http.Request) {
 		log.Printf("[DEBUG] Recebida requisição GET /conta/notificacoes")
 
 		var req struct {
@@ -273,7 +574,7 @@ func main() {
 		notifications, err := accountService.ObterNotificacoes(r.Context(), accountID)
 		if err != nil {
 			log.Printf("[ERROR] Erro ao obter notificações: %v", err)
-			http.Error(w, fmt.Sprintf("Erro ao obter notificações: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Erro ao obter notificações: %v", err), errorStatus(err))
 			return
 		}
 
@@ -310,7 +611,7 @@ func main() {
 
 		transaction, err := accountService.RealizarTransacao(r.Context(), accountID, models.Credit, req.Valor, "", nil, nil)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 
@@ -346,7 +647,7 @@ func main() {
 
 		transaction, err := accountService.RealizarTransacao(r.Context(), accountID, models.Debit, req.Valor, "", nil, nil)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 
@@ -392,7 +693,7 @@ func main() {
 		card, err := accountService.CriarCartao(r.Context(), accountID, req.Limite)
 		if err != nil {
 			log.Printf("[ERROR] Erro ao criar cartão: %v", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 
@@ -435,7 +736,7 @@ func main() {
 		cartao, err := accountService.AlterarStatusCartao(r.Context(), cardID, req.Status)
 		if err != nil {
 			log.Printf("[ERROR] Erro ao alterar status do cartão: %v", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 
@@ -446,7 +747,7 @@ func main() {
 
 	// Rota para alterar limite do cartão
 	log.Printf("[DEBUG] Registrando rota PUT /cartao/limite")
-	cartaoRouter.Methods("PUT").Path("/limite").HandlerFunc(middleware.JWTMiddleware(func(w http.ResponseWriter, r *http.Request) {
+	cartaoRouter.Methods("PUT").Path("/limite").HandlerFunc(middleware.RequirePermission(auth.PermLimitsOverride, func(w http.ResponseWriter, r *http.Request) {
 		log.Printf("[DEBUG] Recebida requisição PUT /cartao/limite")
 
 		var req struct {
@@ -478,7 +779,7 @@ func main() {
 		cartao, err := accountService.AlterarLimiteCartao(r.Context(), cardID, req.Limite)
 		if err != nil {
 			log.Printf("[ERROR] Erro ao alterar limite do cartão: %v", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 
@@ -493,7 +794,9 @@ func main() {
 		log.Printf("[DEBUG] Recebida requisição POST /cartao/virtual")
 
 		var req struct {
-			CartaoID string `json:"cartao_id"`
+			CartaoID string   `json:"cartao_id"`
+			UsoUnico bool     `json:"uso_unico"`
+			Limite   *float64 `json:"limite"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			log.Printf("[ERROR] Erro ao decodificar body da requisição: %v", err)
@@ -501,7 +804,7 @@ func main() {
 			return
 		}
 
-		log.Printf("[DEBUG] Dados recebidos: CartaoID=%s", req.CartaoID)
+		log.Printf("[DEBUG] Dados recebidos: CartaoID=%s, UsoUnico=%t", req.CartaoID, req.UsoUnico)
 
 		if req.CartaoID == "" {
 			log.Printf("[ERROR] Campo obrigatório faltando: CartaoID")
@@ -517,10 +820,10 @@ func main() {
 		}
 
 		log.Printf("[DEBUG] Chamando accountService.GerarCartaoVirtual")
-		virtualCard, err := accountService.GerarCartaoVirtual(r.Context(), cardID)
+		virtualCard, err := accountService.GerarCartaoVirtual(r.Context(), cardID, req.UsoUnico, req.Limite)
 		if err != nil {
 			log.Printf("[ERROR] Erro ao gerar cartão virtual: %v", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 
@@ -529,34 +832,296 @@ func main() {
 		json.NewEncoder(w).Encode(virtualCard)
 	}))
 
-	// Rota para realizar compra com cartão
-	log.Printf("[DEBUG] Registrando rota POST /cartao/comprar")
-	cartaoRouter.Methods("POST").Path("/comprar").HandlerFunc(middleware.JWTMiddleware(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("[DEBUG] Recebida requisição POST /cartao/comprar")
+	// Rota para listar cartões virtuais
+	log.Printf("[DEBUG] Registrando rota GET /cartao/virtual")
+	cartaoRouter.Methods("GET").Path("/virtual").HandlerFunc(middleware.JWTMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cardID, err := uuid.Parse(r.URL.Query().Get("cartao_id"))
+		if err != nil {
+			http.Error(w, "ID do cartão inválido", http.StatusBadRequest)
+			return
+		}
 
-		var req struct {
-			ContaID  string  `json:"conta_id"`
-			CartaoID string  `json:"cartao_id"`
-			Valor    float64 `json:"valor"`
+		cards, err := accountService.ListarCartoesVirtuais(r.Context(), cardID)
+		if err != nil {
+			log.Printf("[ERROR] Erro ao listar cartões virtuais: %v", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
 		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			log.Printf("[ERROR] Erro ao decodificar body da requisição: %v", err)
-			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(cards)
+	}))
+
+	// Rota para excluir cartão virtual
+	log.Printf("[DEBUG] Registrando rota DELETE /cartao/virtual/{id}")
+	cartaoRouter.Methods("DELETE").Path("/virtual/{id}").HandlerFunc(middleware.JWTMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		virtualCardID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "ID do cartão virtual inválido", http.StatusBadRequest)
 			return
 		}
 
-		log.Printf("[DEBUG] Dados recebidos: ContaID=%s, CartaoID=%s, Valor=%.2f", req.ContaID, req.CartaoID, req.Valor)
+		resp, err := accountService.ExcluirCartaoVirtual(r.Context(), virtualCardID)
+		if err != nil {
+			log.Printf("[ERROR] Erro ao excluir cartão virtual: %v", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
 
-		if req.ContaID == "" || req.CartaoID == "" || req.Valor <= 0 {
-			log.Printf("[ERROR] Campos obrigatórios faltando ou inválidos: ContaID=%s, CartaoID=%s, Valor=%.2f", req.ContaID, req.CartaoID, req.Valor)
-			http.Error(w, "Conta ID, cartão ID e valor positivo são obrigatórios", http.StatusBadRequest)
+		log.Printf("[INFO] Cartão virtual %s excluído", virtualCardID)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+
+	// Rotas para realizar compra com cartão e pré-autorizar compras
+	log.Printf("[DEBUG] Registrando rotas POST /cartao/comprar e /cartao/autorizar")
+	compraHandler := func(autorizar bool) http.HandlerFunc {
+		return middleware.RequireScope(auth.PermChargesCreate, func(w http.ResponseWriter, r *http.Request) {
+			log.Printf("[DEBUG] Recebida requisição POST %s", r.URL.Path)
+
+			var req struct {
+				ContaID         string  `json:"conta_id"`
+				CartaoID        string  `json:"cartao_id"`
+				CartaoVirtualID string  `json:"cartao_virtual_id"`
+				Valor           float64 `json:"valor"`
+				Descricao       string  `json:"descricao"`
+				Parcelas        int     `json:"parcelas"`
+				ComJuros        bool    `json:"com_juros"`
+				ValidadeHoras   int     `json:"validade_horas"`
+				Estabelecimento string  `json:"estabelecimento"`
+				MCC             string  `json:"mcc"`
+				Pais            string  `json:"pais"`
+				Canal           string  `json:"canal"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				log.Printf("[ERROR] Erro ao decodificar body da requisição: %v", err)
+				http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+				return
+			}
+
+			log.Printf("[DEBUG] Dados recebidos: ContaID=%s, CartaoID=%s, Valor=%.2f, Parcelas=%d", req.ContaID, req.CartaoID, req.Valor, req.Parcelas)
+
+			if req.ContaID == "" || (req.CartaoID == "" && req.CartaoVirtualID == "") || req.Valor <= 0 {
+				log.Printf("[ERROR] Campos obrigatórios faltando ou inválidos: ContaID=%s, CartaoID=%s, Valor=%.2f", req.ContaID, req.CartaoID, req.Valor)
+				http.Error(w, "Conta ID, cartão ID e valor positivo são obrigatórios", http.StatusBadRequest)
+				return
+			}
+
+			accountID, err := uuid.Parse(req.ContaID)
+			if err != nil {
+				log.Printf("[ERROR] ID da conta inválido: %s - %v", req.ContaID, err)
+				http.Error(w, "ID da conta inválido", http.StatusBadRequest)
+				return
+			}
+
+			var cardID uuid.UUID
+			if req.CartaoID != "" {
+				cardID, err = uuid.Parse(req.CartaoID)
+				if err != nil {
+					log.Printf("[ERROR] ID do cartão inválido: %s - %v", req.CartaoID, err)
+					http.Error(w, "ID do cartão inválido", http.StatusBadRequest)
+					return
+				}
+			}
+
+			var virtualCardID *uuid.UUID
+			if req.CartaoVirtualID != "" {
+				id, err := uuid.Parse(req.CartaoVirtualID)
+				if err != nil {
+					log.Printf("[ERROR] ID do cartão virtual inválido: %s - %v", req.CartaoVirtualID, err)
+					http.Error(w, "ID do cartão virtual inválido", http.StatusBadRequest)
+					return
+				}
+				virtualCardID = &id
+			}
+
+			if req.Parcelas < 0 {
+				log.Printf("[ERROR] Número de parcelas inválido: %d", req.Parcelas)
+				http.Error(w, "Número de parcelas inválido", http.StatusBadRequest)
+				return
+			}
+
+			channel := models.PurchaseChannel(strings.ToUpper(req.Canal))
+			switch channel {
+			case "", models.ChannelOnline, models.ChannelContactless, models.ChannelChip:
+			default:
+				log.Printf("[ERROR] Canal de compra inválido: %s", req.Canal)
+				http.Error(w, "Canal deve ser ONLINE, CONTACTLESS ou CHIP", http.StatusBadRequest)
+				return
+			}
+
+			purchase := models.CardPurchaseRequest{
+				AccountID:     accountID,
+				CreditCardID:  cardID,
+				VirtualCardID: virtualCardID,
+				Amount:        req.Valor,
+				Description:   req.Descricao,
+				Installments:  req.Parcelas,
+				WithInterest:  req.ComJuros,
+				MerchantName:  req.Estabelecimento,
+				MCC:           req.MCC,
+				Country:       strings.ToUpper(req.Pais),
+				Channel:       channel,
+			}
+
+			var transaction *models.APIResponse
+			if autorizar {
+				log.Printf("[DEBUG] Chamando accountService.AutorizarCompraCartao")
+				transaction, err = accountService.AutorizarCompraCartao(r.Context(), purchase, time.Duration(req.ValidadeHoras)*time.Hour)
+			} else {
+				log.Printf("[DEBUG] Chamando accountService.RealizarCompraCartao")
+				transaction, err = accountService.RealizarCompraCartao(r.Context(), purchase)
+			}
+			if err != nil {
+				log.Printf("[ERROR] Erro ao realizar compra com cartão: %v", err)
+				http.Error(w, err.Error(), errorStatus(err))
+				return
+			}
+
+			if transactionData, ok := transaction.Data.(models.Transaction); ok {
+				log.Printf("[INFO] Compra realizada com sucesso: ID=%s, Valor=%.2f, Parcelas=%d", transactionData.ID, transactionData.Amount, transactionData.Installments)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(transaction)
+		})
+	}
+	cartaoRouter.Methods("POST").Path("/comprar").HandlerFunc(compraHandler(false))
+	cartaoRouter.Methods("POST").Path("/autorizar").HandlerFunc(compraHandler(true))
+
+	// Rotas para capturar e cancelar pré-autorizações
+	log.Printf("[DEBUG] Registrando rotas POST /cartao/autorizacao")
+	autorizacaoHandler := func(capturar bool) http.HandlerFunc {
+		return middleware.RequireScope(auth.PermChargesCreate, func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				ContaID       string   `json:"conta_id"`
+				AutorizacaoID string   `json:"autorizacao_id"`
+				Valor         *float64 `json:"valor"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				log.Printf("[ERROR] Erro ao decodificar body da requisição: %v", err)
+				http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+				return
+			}
+
+			if req.ContaID == "" || req.AutorizacaoID == "" {
+				http.Error(w, "Conta ID e autorização ID são obrigatórios", http.StatusBadRequest)
+				return
+			}
+
+			accountID, err := uuid.Parse(req.ContaID)
+			if err != nil {
+				http.Error(w, "ID da conta inválido", http.StatusBadRequest)
+				return
+			}
+
+			authorizationID, err := uuid.Parse(req.AutorizacaoID)
+			if err != nil {
+				http.Error(w, "ID da autorização inválido", http.StatusBadRequest)
+				return
+			}
+
+			var resp *models.APIResponse
+			if capturar {
+				resp, err = accountService.CapturarAutorizacao(r.Context(), accountID, authorizationID, req.Valor)
+			} else {
+				resp, err = accountService.CancelarAutorizacao(r.Context(), accountID, authorizationID)
+			}
+			if err != nil {
+				log.Printf("[ERROR] Erro ao processar pré-autorização: %v", err)
+				http.Error(w, err.Error(), errorStatus(err))
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(resp)
+		})
+	}
+	cartaoRouter.Methods("POST").Path("/autorizacao/capturar").HandlerFunc(autorizacaoHandler(true))
+	cartaoRouter.Methods("POST").Path("/autorizacao/cancelar").HandlerFunc(autorizacaoHandler(false))
+
+	// Rotas para antecipação de parcelas
+	log.Printf("[DEBUG] Registrando rotas POST /cartao/antecipacao")
+	antecipacaoHandler := func(confirmar bool) http.HandlerFunc {
+		return middleware.JWTMiddleware(func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				ContaID  string `json:"conta_id"`
+				CompraID string `json:"compra_id"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				log.Printf("[ERROR] Erro ao decodificar body da requisição: %v", err)
+				http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+				return
+			}
+
+			if req.ContaID == "" || req.CompraID == "" {
+				http.Error(w, "Conta ID e compra ID são obrigatórios", http.StatusBadRequest)
+				return
+			}
+
+			accountID, err := uuid.Parse(req.ContaID)
+			if err != nil {
+				http.Error(w, "ID da conta inválido", http.StatusBadRequest)
+				return
+			}
+
+			purchaseID, err := uuid.Parse(req.CompraID)
+			if err != nil {
+				http.Error(w, "ID da compra inválido", http.StatusBadRequest)
+				return
+			}
+
+			var resp *models.APIResponse
+			if confirmar {
+				resp, err = accountService.AnteciparParcelas(r.Context(), accountID, purchaseID)
+			} else {
+				resp, err = accountService.SimularAntecipacao(r.Context(), accountID, purchaseID)
+			}
+			if err != nil {
+				log.Printf("[ERROR] Erro na antecipação de parcelas: %v", err)
+				http.Error(w, err.Error(), errorStatus(err))
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(resp)
+		})
+	}
+	cartaoRouter.Methods("POST").Path("/antecipacao/simular").HandlerFunc(antecipacaoHandler(false))
+	cartaoRouter.Methods("POST").Path("/antecipacao").HandlerFunc(antecipacaoHandler(true))
+
+	// Rotas para controles de uso do cartão
+	log.Printf("[DEBUG] Registrando rotas GET/PUT /cartao/controles")
+	cartaoRouter.Methods("GET").Path("/controles").HandlerFunc(middleware.JWTMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		cardID, err := uuid.Parse(r.URL.Query().Get("cartao_id"))
+		if err != nil {
+			http.Error(w, "ID do cartão inválido", http.StatusBadRequest)
 			return
 		}
 
-		accountID, err := uuid.Parse(req.ContaID)
+		controls, err := accountService.ObterControlesCartao(r.Context(), cardID)
 		if err != nil {
-			log.Printf("[ERROR] ID da conta inválido: %s - %v", req.ContaID, err)
-			http.Error(w, "ID da conta inválido", http.StatusBadRequest)
+			log.Printf("[ERROR] Erro ao obter controles do cartão: %v", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(controls)
+	}))
+
+	cartaoRouter.Methods("PUT").Path("/controles").HandlerFunc(middleware.JWTMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("[DEBUG] Recebida requisição PUT /cartao/controles")
+
+		var req struct {
+			CartaoID                         string             `json:"cartao_id"`
+			CategoriasBloqueadas             []string           `json:"categorias_bloqueadas"`
+			ComprasOnlineDesativadas         bool               `json:"compras_online_desativadas"`
+			ComprasInternacionaisDesativadas bool               `json:"compras_internacionais_desativadas"`
+			LimitesPorCategoria              map[string]float64 `json:"limites_por_categoria"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("[ERROR] Erro ao decodificar body da requisição: %v", err)
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
 			return
 		}
 
@@ -567,18 +1132,29 @@ func main() {
 			return
 		}
 
-		log.Printf("[DEBUG] Chamando accountService.RealizarTransacao para compra com cartão")
-		transaction, err := accountService.RealizarTransacao(r.Context(), accountID, models.CardPurchase, req.Valor, "", nil, &cardID)
+		controls := models.CardControls{
+			CreditCardID:          cardID,
+			OnlineDisabled:        req.ComprasOnlineDesativadas,
+			InternationalDisabled: req.ComprasInternacionaisDesativadas,
+			CategoryLimits:        map[models.MerchantCategory]float64{},
+		}
+		for _, category := range req.CategoriasBloqueadas {
+			controls.BlockedCategories = append(controls.BlockedCategories, models.MerchantCategory(strings.ToUpper(category)))
+		}
+		for category, limit := range req.LimitesPorCategoria {
+			controls.CategoryLimits[models.MerchantCategory(strings.ToUpper(category))] = limit
+		}
+
+		resp, err := accountService.ConfigurarControlesCartao(r.Context(), controls)
 		if err != nil {
-			log.Printf("[ERROR] Erro ao realizar compra com cartão: %v", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			log.Printf("[ERROR] Erro ao configurar controles do cartão: %v", err)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 
-		transactionData := transaction.Data.(models.Transaction)
-		log.Printf("[INFO] Compra realizada com sucesso: ID=%s, Valor=%.2f", transactionData.ID, transactionData.Amount)
+		log.Printf("[INFO] Controles do cartão %s atualizados", cardID)
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(transaction)
+		json.NewEncoder(w).Encode(resp)
 	}))
 
 	// Rota para pagar fatura do cartão
@@ -589,6 +1165,7 @@ func main() {
 		var req struct {
 			ContaID  string  `json:"conta_id"`
 			CartaoID string  `json:"cartao_id"`
+			FaturaID string  `json:"fatura_id"`
 			Valor    float64 `json:"valor"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -619,20 +1196,52 @@ func main() {
 			return
 		}
 
-		log.Printf("[DEBUG] Chamando accountService.RealizarTransacao para pagamento de fatura")
-		transaction, err := accountService.RealizarTransacao(r.Context(), accountID, models.CardPayment, req.Valor, "", nil, &cardID)
+		var invoiceID *uuid.UUID
+		if req.FaturaID != "" {
+			id, err := uuid.Parse(req.FaturaID)
+			if err != nil {
+				log.Printf("[ERROR] ID da fatura inválido: %s - %v", req.FaturaID, err)
+				http.Error(w, "ID da fatura inválido", http.StatusBadRequest)
+				return
+			}
+			invoiceID = &id
+		}
+
+		log.Printf("[DEBUG] Chamando accountService.PagarFatura")
+		transaction, err := accountService.PagarFatura(r.Context(), accountID, cardID, req.Valor, invoiceID)
 		if err != nil {
 			log.Printf("[ERROR] Erro ao realizar pagamento de fatura: %v", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 
-		transactionData := transaction.Data.(models.Transaction)
-		log.Printf("[INFO] Pagamento de fatura realizado com sucesso: ID=%s, Valor=%.2f", transactionData.ID, transactionData.Amount)
+		if transactionData, ok := transaction.Data.(models.Transaction); ok {
+			log.Printf("[INFO] Pagamento de fatura realizado com sucesso: ID=%s, Valor=%.2f", transactionData.ID, transactionData.Amount)
+		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(transaction)
 	}))
 
+	// Rota para listar faturas do cartão
+	log.Printf("[DEBUG] Registrando rota GET /cartao/faturas")
+	cartaoRouter.Methods("GET").Path("/faturas").HandlerFunc(middleware.RequireScope(auth.PermStatementsRead, func(w http.ResponseWriter, r *http.Request) {
+		cardID, err := uuid.Parse(r.URL.Query().Get("cartao_id"))
+		if err != nil {
+			http.Error(w, "ID do cartão inválido", http.StatusBadRequest)
+			return
+		}
+
+		invoices, err := accountService.ListarFaturas(r.Context(), cardID)
+		if err != nil {
+			log.Printf("[ERROR] Erro ao listar faturas: %v", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(invoices)
+	}))
+
 	// Rotas de PIX
 	pixRouter := router.PathPrefix("/pix").Subrouter()
 
@@ -665,7 +1274,7 @@ func main() {
 
 		pixKey, err := accountService.RegistrarChavePix(r.Context(), accountID, req.Tipo, req.Chave)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 
@@ -702,7 +1311,7 @@ func main() {
 
 		transaction, err := accountService.RealizarTransacao(r.Context(), accountID, models.PIXSent, req.Valor, "", &req.ChaveDestino, nil)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 
@@ -710,7 +1319,7 @@ func main() {
 		json.NewEncoder(w).Encode(transaction)
 	})).Methods("POST")
 
-	pixRouter.HandleFunc("/qrcode", middleware.JWTMiddleware(func(w http.ResponseWriter, r *http.Request) {
+	pixRouter.HandleFunc("/qrcode", middleware.RequireScope(auth.PermChargesCreate, func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
 			return
@@ -738,7 +1347,7 @@ func main() {
 
 		qrCode, err := accountService.GerarQRCodePix(r.Context(), accountID, req.Valor, "")
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 
@@ -757,15 +1366,22 @@ func main() {
 		}
 
 		var req struct {
-			PixID string `json:"pix_id"`
+			ContaID string `json:"conta_id"`
+			PixID   string `json:"pix_id"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
 			return
 		}
 
-		if req.PixID == "" {
-			http.Error(w, "PIX ID é obrigatório", http.StatusBadRequest)
+		if req.ContaID == "" || req.PixID == "" {
+			http.Error(w, "Conta ID e PIX ID são obrigatórios", http.StatusBadRequest)
+			return
+		}
+
+		accountID, err := uuid.Parse(req.ContaID)
+		if err != nil {
+			http.Error(w, "ID da conta inválido", http.StatusBadRequest)
 			return
 		}
 
@@ -775,9 +1391,9 @@ func main() {
 			return
 		}
 
-		pix, err := accountService.CancelarAgendamentoPix(r.Context(), pixID)
+		pix, err := accountService.CancelarAgendamentoPix(r.Context(), accountID, pixID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 
@@ -785,6 +1401,182 @@ func main() {
 		json.NewEncoder(w).Encode(pix)
 	})).Methods("POST")
 
+	// Rotas de back-office, restritas à equipe do banco
+	adminRouter := router.PathPrefix("/admin").Subrouter()
+	log.Printf("[DEBUG] Registrando rotas de administração...")
+
+	congelarHandler := func(congelar bool) http.HandlerFunc {
+		return middleware.RequirePermission(auth.PermAccountsManage, func(w http.ResponseWriter, r *http.Request) {
+			accountID, err := uuid.Parse(mux.Vars(r)["id"])
+			if err != nil {
+				http.Error(w, "ID da conta inválido", http.StatusBadRequest)
+				return
+			}
+
+			resp, err := accountService.CongelarConta(r.Context(), accountID, congelar)
+			if err != nil {
+				log.Printf("[ERROR] Erro ao alterar congelamento da conta: %v", err)
+				http.Error(w, err.Error(), errorStatus(err))
+				return
+			}
+
+			log.Printf("[INFO] Conta %s congelada=%t", accountID, congelar)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(resp)
+		})
+	}
+	adminRouter.Methods("POST").Path("/contas/{id}/congelar").HandlerFunc(congelarHandler(true))
+	adminRouter.Methods("POST").Path("/contas/{id}/descongelar").HandlerFunc(congelarHandler(false))
+
+	adminRouter.Methods("PUT").Path("/cartoes/{id}/limite").HandlerFunc(middleware.RequirePermission(auth.PermLimitsOverride, func(w http.ResponseWriter, r *http.Request) {
+		cardID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "ID do cartão inválido", http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			Limite float64 `json:"limite"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if req.Limite < 0 {
+			http.Error(w, "Limite não pode ser negativo", http.StatusBadRequest)
+			return
+		}
+
+		resp, err := accountService.AlterarLimiteCartao(r.Context(), cardID, req.Limite)
+		if err != nil {
+			log.Printf("[ERROR] Erro ao sobrescrever limite do cartão: %v", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+
+		log.Printf("[INFO] Limite do cartão %s sobrescrito para %.2f", cardID, req.Limite)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+
+	adminRouter.Methods("GET").Path("/clientes").HandlerFunc(middleware.RequirePermission(auth.PermCustomersSearch, func(w http.ResponseWriter, r *http.Request) {
+		resp, err := accountService.BuscarClientes(r.Context(), r.URL.Query().Get("q"))
+		if err != nil {
+			log.Printf("[ERROR] Erro ao buscar clientes: %v", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+
+	adminRouter.Methods("GET").Path("/auditoria").HandlerFunc(middleware.RequirePermission(auth.PermAuditRead, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filtro := audit.Filter{
+			ActorID:    query.Get("ator"),
+			Action:     query.Get("acao"),
+			EntityType: query.Get("entidade_tipo"),
+			EntityID:   query.Get("entidade_id"),
+		}
+		for param, dest := range map[string]*time.Time{"desde": &filtro.Since, "ate": &filtro.Until} {
+			if v := query.Get(param); v != "" {
+				t, err := time.Parse(time.RFC3339, v)
+				if err != nil {
+					http.Error(w, fmt.Sprintf("Parâmetro %s inválido, use RFC3339", param), http.StatusBadRequest)
+					return
+				}
+				*dest = t
+			}
+		}
+		if v := query.Get("limite"); v != "" {
+			limite, err := strconv.Atoi(v)
+			if err != nil {
+				http.Error(w, "Parâmetro limite inválido", http.StatusBadRequest)
+				return
+			}
+			filtro.Limit = limite
+		}
+
+		resp, err := accountService.ConsultarAuditoria(r.Context(), filtro)
+		if err != nil {
+			log.Printf("[ERROR] Erro ao consultar auditoria: %v", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+
+	adminRouter.Methods("GET").Path("/auditoria/verificar").HandlerFunc(middleware.RequirePermission(auth.PermAuditRead, func(w http.ResponseWriter, r *http.Request) {
+		resp, err := accountService.VerificarAuditoria(r.Context())
+		if err != nil {
+			log.Printf("[ERROR] Erro ao verificar auditoria: %v", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+
+		if !resp.Success {
+			log.Printf("[ERROR] %s", resp.Message)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+
+	adminRouter.Methods("POST").Path("/chaves-api").HandlerFunc(middleware.RequirePermission(auth.PermAPIKeysManage, func(w http.ResponseWriter, r *http.Request) {
+		var req models.CreateAPIKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		resp, err := apiKeyService.CriarChave(r.Context(), req)
+		if err != nil {
+			log.Printf("[ERROR] Erro ao criar chave de API: %v", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+
+		if resp.Success {
+			log.Printf("[INFO] Chave de API criada: %s", req.Nome)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+
+	adminRouter.Methods("GET").Path("/chaves-api").HandlerFunc(middleware.RequirePermission(auth.PermAPIKeysManage, func(w http.ResponseWriter, r *http.Request) {
+		resp, err := apiKeyService.ListarChaves(r.Context())
+		if err != nil {
+			log.Printf("[ERROR] Erro ao listar chaves de API: %v", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+
+	adminRouter.Methods("DELETE").Path("/chaves-api/{id}").HandlerFunc(middleware.RequirePermission(auth.PermAPIKeysManage, func(w http.ResponseWriter, r *http.Request) {
+		keyID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "ID da chave inválido", http.StatusBadRequest)
+			return
+		}
+
+		resp, err := apiKeyService.RevogarChave(r.Context(), keyID)
+		if err != nil {
+			log.Printf("[ERROR] Erro ao revogar chave de API: %v", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+
+		log.Printf("[INFO] Chave de API %s revogada", keyID)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+
 	// Log todas as rotas registradas
 	router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
 		path, _ := route.GetPathTemplate()
@@ -802,15 +1594,48 @@ func main() {
 	log.Printf("[INFO] Starting HTTP server on %s", srv.Addr)
 
 	// Iniciar servidor HTTP
-	if err := srv.ListenAndServe(); err != nil {
-		log.Fatalf("[ERROR] HTTP server error: %v", err)
+	app.Go("servidor HTTP", func(ctx context.Context) error {
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			return err
+		}
+		// Shutdown já foi chamado; espera a etapa de parada
+		<-ctx.Done()
+		return nil
+	})
+	// Parar de aceitar conexões e esperar as requisições em andamento
+	app.OnStop("requisições HTTP", srv.Shutdown)
+
+	// Aguardar sinal de término ou falha de uma tarefa
+	if err := app.Run(); err != nil {
+		log.Fatalf("[ERROR] Shutdown finished with errors: %v", err)
+	}
+	log.Printf("[INFO] Shutdown complete")
+}
+
+// errorStatus traduz o erro devolvido pelo serviço no status HTTP: acesso a
+// conta ou cartão de outro cliente é 403, falhas de login são 401 e 423, o
+// resto é erro interno.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrInvalidCredentials), errors.Is(err, services.ErrTOTPRequired):
+		return http.StatusUnauthorized
+	case errors.Is(err, services.ErrUserLocked), errors.Is(err, services.ErrPINBlocked):
+		return http.StatusLocked
+	case errors.Is(err, services.ErrPINRequired), errors.Is(err, services.ErrPINNotSet):
+		return http.StatusPreconditionRequired
+	case errors.Is(err, services.ErrPINInvalid):
+		return http.StatusForbidden
 	}
+	return http.StatusInternalServerError
+}
 
-	// Aguardar sinal de término
-	select {
-	case <-signals:
-		log.Println("Received shutdown signal")
-	case <-done:
-		log.Println("Shutting down due to error")
+// dispositivo identifica o aparelho do cliente pelos cabeçalhos da requisição.
+func dispositivo(r *http.Request) services.Dispositivo {
+	return services.Dispositivo{
+		ID:        r.Header.Get("X-Device-ID"),
+		Nome:      r.Header.Get("X-Device-Name"),
+		UserAgent: r.UserAgent(),
 	}
 }
@@ -0,0 +1,147 @@
+// Comando replay relê os tópicos Kafka e aplica as mensagens em um banco de
+// destino com os mesmos handlers do consumidor da API. Ao final, compara o
+// destino com o banco atual e lista as divergências encontradas.
+//
+// Uso:
+//
+//	replay -target <dsn> [-source <dsn>] [-offset N | -since 2024-01-31T00:00:00Z]
+//	       [-topics accounts,pix-keys,credit-cards,transactions] [-dry-run]
+//
+// Com -dry-run tudo roda dentro de uma transação no destino, inclusive a
+// criação das tabelas, desfeita depois da comparação. O banco atual vem de
+// -source ou de DATABASE_URL e precisa ser outro banco que não o destino. A
+// conexão com o Kafka usa as mesmas variáveis KAFKA_* da API.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/red-velvet-workspace/banco-digital/internal/infrastructure/database"
+	"github.com/red-velvet-workspace/banco-digital/internal/infrastructure/kafka"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	target := flag.String("target", "", "DSN do banco de destino (obrigatório)")
+	source := flag.String("source", os.Getenv("DATABASE_URL"), "DSN do banco atual usado na comparação")
+	offset := flag.Int64("offset", -1, "offset inicial em cada partição (padrão: o mais antigo retido)")
+	since := flag.String("since", "", "relê as mensagens publicadas a partir deste instante (RFC3339)")
+	topics := flag.String("topics", strings.Join(kafka.ProjectedTopics, ","), "tópicos relidos, separados por vírgula")
+	dryRun := flag.Bool("dry-run", false, "desfaz as alterações no destino depois da comparação")
+	flag.Parse()
+
+	if *target == "" || *source == "" {
+		usage()
+	}
+	from := kafka.ReplayFrom{Offset: *offset}
+	if *since != "" {
+		t, err := time.Parse(time.RFC3339, *since)
+		if err != nil {
+			log.Fatalf("[ERROR] -since inválido: %v", err)
+		}
+		from.Since = t
+	}
+
+	targetDB, err := open(*target)
+	if err != nil {
+		log.Fatalf("[ERROR] Erro ao conectar ao banco de destino: %v", err)
+	}
+	sourceDB, err := open(*source)
+	if err != nil {
+		log.Fatalf("[ERROR] Erro ao conectar ao banco atual: %v", err)
+	}
+	// Comparar o destino com ele mesmo nunca acusaria divergência
+	same, err := sameDatabase(sourceDB, targetDB)
+	if err != nil {
+		log.Fatalf("[ERROR] Erro ao identificar os bancos: %v", err)
+	}
+	if same {
+		log.Fatalf("[ERROR] -source e -target apontam para o mesmo banco; use um banco de destino separado")
+	}
+
+	client, err := kafka.LoadClientConfig()
+	if err != nil {
+		log.Fatalf("[ERROR] Configuração do Kafka inválida: %v", err)
+	}
+	replay, err := kafka.OpenReplay(client)
+	if err != nil {
+		log.Fatalf("[ERROR] Erro ao conectar ao Kafka: %v", err)
+	}
+	defer replay.Close()
+
+	db := targetDB
+	if *dryRun {
+		db = targetDB.Begin()
+		if db.Error != nil {
+			log.Fatalf("[ERROR] Erro ao abrir a transação no destino: %v", db.Error)
+		}
+		defer db.Rollback()
+	}
+	// No dry-run as tabelas são criadas dentro da transação e desfeitas junto
+	if err := database.InitDB(db); err != nil {
+		log.Fatalf("[ERROR] Erro ao preparar o banco de destino: %v", err)
+	}
+
+	projector := kafka.NewProjector(db)
+	applied, failed := 0, 0
+	err = replay.Read(strings.Split(*topics, ","), from, func(msg *kafka.Message) error {
+		if err := projector.Apply(msg); err != nil {
+			failed++
+			log.Printf("[ERROR] Erro ao aplicar %s/%d/%d: %v", msg.Topic, msg.Partition, msg.Offset, err)
+			return nil
+		}
+		applied++
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("[ERROR] Erro ao reler os tópicos: %v", err)
+	}
+	log.Printf("[INFO] %d mensagem(ns) aplicada(s), %d com erro", applied, failed)
+
+	divergences, err := compare(sourceDB, db)
+	if err != nil {
+		log.Fatalf("[ERROR] Erro ao comparar os bancos: %v", err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, d := range divergences {
+		enc.Encode(d)
+	}
+	log.Printf("[INFO] %d divergência(s) encontrada(s)", len(divergences))
+	if *dryRun {
+		log.Printf("[INFO] Dry-run: alterações no destino desfeitas")
+	}
+}
+
+func open(dsn string) (*gorm.DB, error) {
+	return gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+}
+
+// sameDatabase diz se as duas conexões chegam ao mesmo banco, mesmo com DSNs
+// escritos de forma diferente.
+func sameDatabase(a, b *gorm.DB) (bool, error) {
+	const identity = "SELECT current_database() || '@' || COALESCE(host(inet_server_addr()), 'socket') || ':' || COALESCE(inet_server_port()::text, '')"
+	var idA, idB string
+	if err := a.Raw(identity).Scan(&idA).Error; err != nil {
+		return false, err
+	}
+	if err := b.Raw(identity).Scan(&idB).Error; err != nil {
+		return false, err
+	}
+	return idA == idB, nil
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Uso:
+  replay -target <dsn> [-source <dsn>] [-offset N | -since <RFC3339>] [-topics t1,t2] [-dry-run]
+
+O banco atual vem de -source ou de DATABASE_URL e não pode ser o destino.`)
+	os.Exit(2)
+}
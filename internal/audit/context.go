@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"context"
+	"strings"
+
+	"github.com/red-velvet-workspace/banco-digital/internal/auth"
+)
+
+// Actor identifica quem originou uma alteração. Ele viaja junto das
+// mensagens Kafka para que o consumidor registre o autor da requisição.
+type Actor struct {
+	ID         string `json:"id,omitempty"`
+	Role       string `json:"role,omitempty"`
+	IP         string `json:"ip,omitempty"`
+	RequestID  string `json:"request_id,omitempty"`
+	AuthMethod string `json:"auth_method,omitempty"`
+}
+
+// System é o ator de tarefas internas, como a expiração de pré-autorizações.
+var System = Actor{ID: "system", Role: "system"}
+
+type requestInfo struct {
+	requestID string
+	ip        string
+}
+
+type contextKey struct{}
+
+// WithRequest guarda o ID e o IP de origem da requisição no contexto.
+func WithRequest(ctx context.Context, requestID string, ip string) context.Context {
+	return context.WithValue(ctx, contextKey{}, requestInfo{requestID: requestID, ip: ip})
+}
+
+// RequestID devolve o ID guardado por WithRequest.
+func RequestID(ctx context.Context) string {
+	info, _ := ctx.Value(contextKey{}).(requestInfo)
+	return info.requestID
+}
+
+// IP devolve o IP de origem guardado por WithRequest.
+func IP(ctx context.Context) string {
+	info, _ := ctx.Value(contextKey{}).(requestInfo)
+	return info.ip
+}
+
+// ActorFromContext monta o ator a partir do principal autenticado e dos
+// dados da requisição. Sem principal o ator fica anônimo.
+func ActorFromContext(ctx context.Context) Actor {
+	info, _ := ctx.Value(contextKey{}).(requestInfo)
+	actor := Actor{IP: info.ip, RequestID: info.requestID}
+	if principal, ok := auth.FromContext(ctx); ok {
+		actor.ID = principal.Subject
+		actor.AuthMethod = principal.AuthMethod()
+		roles := make([]string, 0, len(principal.Roles))
+		for _, role := range principal.Roles {
+			roles = append(roles, string(role))
+		}
+		actor.Role = strings.Join(roles, ",")
+	}
+	return actor
+}
@@ -0,0 +1,174 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/red-velvet-workspace/banco-digital/internal/domain/models"
+	"gorm.io/gorm"
+)
+
+// chainLockID é a chave do advisory lock que serializa a inclusão de
+// registros, garantindo que cada um encadeie no último gravado.
+const chainLockID = 0x61756469
+
+// Record grava um registro no log de auditoria. db pode ser a transação
+// que aplica a alteração, assim o registro só existe se ela for confirmada.
+// before e after são serializados em JSON; nil é gravado vazio.
+func Record(db *gorm.DB, actor Actor, action, entityType, entityID string, before, after interface{}) error {
+	beforeJSON, err := snapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := snapshot(after)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", chainLockID).Error; err != nil {
+			return err
+		}
+
+		var last models.AuditEntry
+		if err := tx.Select("hash").Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+			return err
+		}
+
+		entry := models.AuditEntry{
+			ActorID:    actor.ID,
+			ActorRole:  actor.Role,
+			IP:         actor.IP,
+			RequestID:  actor.RequestID,
+			AuthMethod: actor.AuthMethod,
+			Action:     action,
+			EntityType: entityType,
+			EntityID:   entityID,
+			Before:     beforeJSON,
+			After:      afterJSON,
+			// O Postgres guarda microssegundos; o hash usa a mesma precisão
+			CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+			PrevHash:  last.Hash,
+		}
+		entry.Hash = Hash(entry)
+		return tx.Create(&entry).Error
+	})
+}
+
+// Hash calcula o hash do registro a partir do seu conteúdo e do PrevHash.
+func Hash(entry models.AuditEntry) string {
+	fields := []string{
+		entry.PrevHash,
+		entry.ActorID,
+		entry.ActorRole,
+		entry.IP,
+		entry.RequestID,
+		entry.Action,
+		entry.EntityType,
+		entry.EntityID,
+		entry.Before,
+		entry.After,
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	// Registros gravados antes do campo existir continuam com o mesmo hash
+	if entry.AuthMethod != "" {
+		fields = append(fields, entry.AuthMethod)
+	}
+	h := sha256.New()
+	for _, f := range fields {
+		// Prefixar o tamanho evita que campos diferentes gerem o mesmo texto
+		h.Write([]byte(strconv.Itoa(len(f)) + ":" + f))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Verify percorre a cadeia em ordem e devolve o ID do primeiro registro
+// adulterado, ou zero se a cadeia estiver íntegra.
+func Verify(ctx context.Context, db *gorm.DB) (uint64, error) {
+	var prev string
+	var broken uint64
+	var batch []models.AuditEntry
+	err := db.WithContext(ctx).Order("id").FindInBatches(&batch, 1000, func(tx *gorm.DB, _ int) error {
+		prev, broken = verifyChain(prev, batch)
+		if broken != 0 {
+			return errChainBroken
+		}
+		return nil
+	}).Error
+	if errors.Is(err, errChainBroken) {
+		return broken, nil
+	}
+	return 0, err
+}
+
+// verifyChain confere entries, em ordem de ID, a partir do hash prev do
+// registro anterior. Devolve o hash do último registro e o ID do primeiro
+// adulterado, ou zero se o trecho estiver íntegro.
+func verifyChain(prev string, entries []models.AuditEntry) (string, uint64) {
+	for _, entry := range entries {
+		if entry.PrevHash != prev || Hash(entry) != entry.Hash {
+			return prev, entry.ID
+		}
+		prev = entry.Hash
+	}
+	return prev, 0
+}
+
+var errChainBroken = errors.New("cadeia de auditoria quebrada")
+
+func snapshot(v interface{}) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Filter restringe a consulta do log. Campos vazios não filtram.
+type Filter struct {
+	ActorID    string
+	Action     string
+	EntityType string
+	EntityID   string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+}
+
+// Query devolve os registros mais recentes que atendem ao filtro.
+func Query(ctx context.Context, db *gorm.DB, f Filter) ([]models.AuditEntry, error) {
+	q := db.WithContext(ctx).Model(&models.AuditEntry{})
+	if f.ActorID != "" {
+		q = q.Where("actor_id = ?", f.ActorID)
+	}
+	if f.Action != "" {
+		q = q.Where("action = ?", f.Action)
+	}
+	if f.EntityType != "" {
+		q = q.Where("entity_type = ?", f.EntityType)
+	}
+	if f.EntityID != "" {
+		q = q.Where("entity_id = ?", f.EntityID)
+	}
+	if !f.Since.IsZero() {
+		q = q.Where("created_at >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		q = q.Where("created_at < ?", f.Until)
+	}
+	if f.Limit <= 0 || f.Limit > 500 {
+		f.Limit = 500
+	}
+
+	var entries []models.AuditEntry
+	err := q.Order("id DESC").Limit(f.Limit).Find(&entries).Error
+	return entries, err
+}
@@ -0,0 +1,123 @@
+package audit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/red-velvet-workspace/banco-digital/internal/domain/models"
+)
+
+// buildChain monta n registros encadeados como Record faria.
+func buildChain(n int) []models.AuditEntry {
+	entries := make([]models.AuditEntry, n)
+	prev := ""
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := range entries {
+		entry := models.AuditEntry{
+			ID:         uint64(i + 1),
+			ActorID:    "funcionario-1",
+			ActorRole:  "risk",
+			IP:         "203.0.113.7",
+			RequestID:  fmt.Sprintf("req-%d", i+1),
+			AuthMethod: "jwt",
+			Action:     "account.status_update",
+			EntityType: "account",
+			EntityID:   fmt.Sprintf("conta-%d", i+1),
+			Before:     `{"status":"ACTIVE"}`,
+			After:      `{"status":"FROZEN"}`,
+			CreatedAt:  start.Add(time.Duration(i) * time.Second),
+			PrevHash:   prev,
+		}
+		entry.Hash = Hash(entry)
+		prev = entry.Hash
+		entries[i] = entry
+	}
+	return entries
+}
+
+func TestVerifyChain(t *testing.T) {
+	tests := []struct {
+		name       string
+		tamper     func([]models.AuditEntry) []models.AuditEntry
+		wantBroken uint64
+	}{
+		{"íntegra", func(e []models.AuditEntry) []models.AuditEntry { return e }, 0},
+		{"campo editado", func(e []models.AuditEntry) []models.AuditEntry {
+			e[2].After = `{"status":"ACTIVE"}`
+			return e
+		}, 3},
+		{"ator editado", func(e []models.AuditEntry) []models.AuditEntry {
+			e[0].ActorID = "outro"
+			return e
+		}, 1},
+		{"registro editado com hash recalculado", func(e []models.AuditEntry) []models.AuditEntry {
+			e[1].IP = "198.51.100.1"
+			e[1].Hash = Hash(e[1])
+			return e
+		}, 3},
+		{"registro removido", func(e []models.AuditEntry) []models.AuditEntry {
+			return append(e[:2], e[3:]...)
+		}, 4},
+		{"último registro removido", func(e []models.AuditEntry) []models.AuditEntry {
+			return e[:len(e)-1]
+		}, 0},
+		{"registros trocados de posição", func(e []models.AuditEntry) []models.AuditEntry {
+			// Os IDs seguem a ordem da tabela; o conteúdo troca de lugar
+			e[1], e[3] = e[3], e[1]
+			e[1].ID, e[3].ID = 2, 4
+			return e
+		}, 2},
+	}
+	for _, tt := range tests {
+		entries := tt.tamper(buildChain(5))
+		if _, broken := verifyChain("", entries); broken != tt.wantBroken {
+			t.Errorf("%s: registro quebrado %d, esperado %d", tt.name, broken, tt.wantBroken)
+		}
+	}
+}
+
+func TestVerifyChainAcrossBatches(t *testing.T) {
+	entries := buildChain(6)
+	entries[4].EntityID = "conta-adulterada"
+
+	prev, broken := verifyChain("", entries[:3])
+	if broken != 0 {
+		t.Fatalf("primeiro lote: registro quebrado %d", broken)
+	}
+	if _, broken := verifyChain(prev, entries[3:]); broken != 5 {
+		t.Errorf("segundo lote: registro quebrado %d, esperado 5", broken)
+	}
+}
+
+func TestHashCoversEveryField(t *testing.T) {
+	base := buildChain(1)[0]
+	edits := map[string]func(*models.AuditEntry){
+		"PrevHash":   func(e *models.AuditEntry) { e.PrevHash = "x" },
+		"ActorID":    func(e *models.AuditEntry) { e.ActorID = "x" },
+		"ActorRole":  func(e *models.AuditEntry) { e.ActorRole = "x" },
+		"IP":         func(e *models.AuditEntry) { e.IP = "x" },
+		"RequestID":  func(e *models.AuditEntry) { e.RequestID = "x" },
+		"AuthMethod": func(e *models.AuditEntry) { e.AuthMethod = "api_key" },
+		"Action":     func(e *models.AuditEntry) { e.Action = "x" },
+		"EntityType": func(e *models.AuditEntry) { e.EntityType = "x" },
+		"EntityID":   func(e *models.AuditEntry) { e.EntityID = "x" },
+		"Before":     func(e *models.AuditEntry) { e.Before = "x" },
+		"After":      func(e *models.AuditEntry) { e.After = "x" },
+		"CreatedAt":  func(e *models.AuditEntry) { e.CreatedAt = e.CreatedAt.Add(time.Microsecond) },
+	}
+	for field, edit := range edits {
+		entry := base
+		edit(&entry)
+		if Hash(entry) == base.Hash {
+			t.Errorf("alterar %s não muda o hash", field)
+		}
+	}
+
+	// O prefixo de tamanho impede mover texto de um campo para o vizinho
+	shifted := base
+	shifted.EntityType, shifted.EntityID = base.EntityType+base.EntityID[:1], base.EntityID[1:]
+	if Hash(shifted) == base.Hash {
+		t.Error("texto movido entre campos gera o mesmo hash")
+	}
+}
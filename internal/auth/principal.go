@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Métodos de autenticação registrados na auditoria.
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodAPIKey = "api_key"
+)
+
+// Principal identifica quem fez a requisição. Subject vem da claim sub do
+// token; Accounts lista contas de terceiros às quais o token dá acesso
+// (claim accounts), como contas conjuntas ou procurações. Sem claim de papel
+// o principal é um cliente.
+//
+// Requisições com chave de API têm APIKeyID preenchido, nenhum papel e só
+// podem o que os Scopes da chave permitem.
+type Principal struct {
+	Subject  string
+	Roles    []Role
+	Accounts []uuid.UUID
+	Claims   jwt.MapClaims
+	APIKeyID *uuid.UUID
+	Scopes   []Permission
+	// SessionID vem da claim sid dos tokens emitidos no login
+	SessionID string
+}
+
+// NewPrincipal monta o Principal a partir das claims já validadas.
+func NewPrincipal(claims jwt.MapClaims) *Principal {
+	subject, _ := claims.GetSubject()
+	p := &Principal{Subject: subject, Roles: rolesFromClaims(claims), Claims: claims}
+	p.SessionID, _ = claims["sid"].(string)
+
+	if list, ok := claims["accounts"].([]interface{}); ok {
+		for _, item := range list {
+			s, ok := item.(string)
+			if !ok {
+				continue
+			}
+			if id, err := uuid.Parse(s); err == nil {
+				p.Accounts = append(p.Accounts, id)
+			}
+		}
+	}
+	return p
+}
+
+// NewAPIKeyPrincipal monta o principal de uma chave de API.
+func NewAPIKeyPrincipal(keyID uuid.UUID, scopes []Permission, accounts []uuid.UUID) *Principal {
+	return &Principal{
+		Subject:  "apikey:" + keyID.String(),
+		Accounts: accounts,
+		APIKeyID: &keyID,
+		Scopes:   scopes,
+	}
+}
+
+// AuthMethod informa se a requisição usou JWT ou chave de API.
+func (p *Principal) AuthMethod() string {
+	if p.APIKeyID != nil {
+		return AuthMethodAPIKey
+	}
+	return AuthMethodJWT
+}
+
+// CanAccessAccount informa se o principal é dono da conta ou tem acesso
+// delegado a ela.
+func (p *Principal) CanAccessAccount(accountID uuid.UUID, ownerID string) bool {
+	if ownerID != "" && ownerID == p.Subject {
+		return true
+	}
+	for _, id := range p.Accounts {
+		if id == accountID {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+// NewContext devolve uma cópia de ctx com o principal da requisição.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext devolve o principal guardado por NewContext.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(*Principal)
+	return p, ok && p != nil
+}
@@ -0,0 +1,88 @@
+package auth
+
+// Role é o papel do usuário, lido das claims roles (lista) ou role do token.
+type Role string
+
+const (
+	RoleCustomer Role = "customer"
+	RoleSupport  Role = "support"
+	RoleRisk     Role = "risk"
+	RoleAdmin    Role = "admin"
+)
+
+// Permission é uma ação reservada à equipe do banco. Operações do próprio
+// cliente não precisam de permissão, apenas de posse da conta. Os escopos das
+// chaves de API usam os mesmos nomes, mais os escopos de parceiros abaixo.
+type Permission string
+
+const (
+	PermAccountsManage  Permission = "accounts:manage"
+	PermLimitsOverride  Permission = "limits:override"
+	PermCustomersSearch Permission = "customers:search"
+	PermAuditRead       Permission = "audit:read"
+	PermAPIKeysManage   Permission = "api_keys:manage"
+
+	// Escopos de parceiros, que dispensam o JWT do cliente nas contas
+	// vinculadas à chave
+	PermStatementsRead Permission = "statements:read"
+	PermChargesCreate  Permission = "charges:create"
+)
+
+// APIKeyScopes lista os escopos que podem ser concedidos a uma chave de API.
+// Gerenciar chaves fica de fora para que uma chave não crie outras.
+var APIKeyScopes = []Permission{
+	PermAccountsManage,
+	PermLimitsOverride,
+	PermCustomersSearch,
+	PermAuditRead,
+	PermStatementsRead,
+	PermChargesCreate,
+}
+
+var rolePermissions = map[Role][]Permission{
+	RoleSupport: {PermAccountsManage, PermCustomersSearch},
+	RoleRisk:    {PermAccountsManage, PermLimitsOverride, PermCustomersSearch, PermAuditRead},
+	RoleAdmin:   {PermAccountsManage, PermLimitsOverride, PermCustomersSearch, PermAuditRead, PermAPIKeysManage},
+}
+
+// Can informa se algum papel do principal concede a permissão. Para chaves
+// de API valem os escopos da chave.
+func (p *Principal) Can(permission Permission) bool {
+	if p.APIKeyID != nil {
+		for _, scope := range p.Scopes {
+			if scope == permission {
+				return true
+			}
+		}
+		return false
+	}
+	for _, role := range p.Roles {
+		for _, granted := range rolePermissions[role] {
+			if granted == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func rolesFromClaims(claims map[string]interface{}) []Role {
+	var roles []Role
+	switch v := claims["roles"].(type) {
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				roles = append(roles, Role(s))
+			}
+		}
+	case string:
+		roles = append(roles, Role(v))
+	}
+	if s, ok := claims["role"].(string); ok {
+		roles = append(roles, Role(s))
+	}
+	if len(roles) == 0 {
+		roles = []Role{RoleCustomer}
+	}
+	return roles
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// SecretBox cifra segredos guardados no banco, como os segredos TOTP, com
+// AES-256-GCM. O texto cifrado leva o nonce na frente e é codificado em
+// base64.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+func NewSecretBox(key []byte) (*SecretBox, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("a chave de cifragem deve ter 32 bytes, tem %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+// publishedDevTOTPKey é a chave que já foi versionada nos manifestos de
+// deploy e por isso não protege nada.
+const publishedDevTOTPKey = "ZGV2LXRvdHAtZW5jcnlwdGlvbi1rZXktMzItYnl0ZXM="
+
+// LoadSecretBox lê a chave de TOTP_ENCRYPTION_KEY, 32 bytes em base64.
+func LoadSecretBox() (*SecretBox, error) {
+	encoded := os.Getenv("TOTP_ENCRYPTION_KEY")
+	if encoded == "" {
+		return nil, errors.New("TOTP_ENCRYPTION_KEY é obrigatório")
+	}
+	if encoded == publishedDevTOTPKey {
+		return nil, errors.New("TOTP_ENCRYPTION_KEY usa a chave de desenvolvimento publicada no repositório; gere uma nova (ex.: openssl rand -base64 32)")
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("TOTP_ENCRYPTION_KEY inválido: %v", err)
+	}
+	return NewSecretBox(key)
+}
+
+func (b *SecretBox) Seal(plaintext string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (b *SecretBox) Open(sealed string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	if len(raw) < b.aead.NonceSize() {
+		return "", errors.New("texto cifrado truncado")
+	}
+	nonce, ciphertext := raw[:b.aead.NonceSize()], raw[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
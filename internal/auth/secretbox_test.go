@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+func newTestSecretBox(t *testing.T) *SecretBox {
+	t.Helper()
+	box, err := NewSecretBox(bytes.Repeat([]byte{0x42}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return box
+}
+
+func TestSecretBoxRoundTrip(t *testing.T) {
+	box := newTestSecretBox(t)
+	for _, plaintext := range []string{"", rfc6238Secret, "ção ✓"} {
+		sealed, err := box.Seal(plaintext)
+		if err != nil {
+			t.Fatalf("Seal(%q): %v", plaintext, err)
+		}
+		got, err := box.Open(sealed)
+		if err != nil {
+			t.Fatalf("Open(Seal(%q)): %v", plaintext, err)
+		}
+		if got != plaintext {
+			t.Errorf("Open(Seal(%q)) = %q", plaintext, got)
+		}
+	}
+}
+
+func TestSecretBoxSealUsesFreshNonce(t *testing.T) {
+	box := newTestSecretBox(t)
+	a, _ := box.Seal(rfc6238Secret)
+	b, _ := box.Seal(rfc6238Secret)
+	if a == b {
+		t.Error("o mesmo texto cifrado duas vezes deu o mesmo resultado")
+	}
+}
+
+func TestSecretBoxOpenRejectsTampering(t *testing.T) {
+	box := newTestSecretBox(t)
+	sealed, err := box.Seal(rfc6238Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(sealed)
+
+	other, err := NewSecretBox(bytes.Repeat([]byte{0x24}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		box    *SecretBox
+		sealed string
+	}{
+		{"nonce alterado", box, flipByte(raw, 0)},
+		{"texto cifrado alterado", box, flipByte(raw, box.aead.NonceSize())},
+		{"tag alterada", box, flipByte(raw, len(raw)-1)},
+		{"truncado", box, base64.StdEncoding.EncodeToString(raw[:box.aead.NonceSize()-1])},
+		{"sem a tag", box, base64.StdEncoding.EncodeToString(raw[:len(raw)-box.aead.Overhead()])},
+		{"base64 inválido", box, "%%%"},
+		{"outra chave", other, sealed},
+	}
+	for _, tt := range tests {
+		if got, err := tt.box.Open(tt.sealed); err == nil {
+			t.Errorf("%s: Open aceitou e devolveu %q", tt.name, got)
+		}
+	}
+}
+
+func TestNewSecretBoxKeySize(t *testing.T) {
+	for _, size := range []int{0, 16, 31, 33} {
+		if _, err := NewSecretBox(make([]byte, size)); err == nil {
+			t.Errorf("chave de %d bytes aceita", size)
+		}
+	}
+}
+
+func flipByte(raw []byte, i int) string {
+	b := append([]byte(nil), raw...)
+	b[i] ^= 0x01
+	return base64.StdEncoding.EncodeToString(b)
+}
@@ -0,0 +1,18 @@
+package auth
+
+import "context"
+
+type pinContextKey struct{}
+
+// WithTransactionPIN guarda no contexto o PIN de transação enviado no
+// cabeçalho X-Transaction-PIN, conferido pelos serviços nas operações que
+// exigem confirmação.
+func WithTransactionPIN(ctx context.Context, pin string) context.Context {
+	return context.WithValue(ctx, pinContextKey{}, pin)
+}
+
+// TransactionPIN devolve o PIN guardado por WithTransactionPIN.
+func TransactionPIN(ctx context.Context) string {
+	pin, _ := ctx.Value(pinContextKey{}).(string)
+	return pin
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// TokenIssuer emite access tokens HS256 com o mesmo segredo, iss e aud que o
+// middleware usa na verificação.
+type TokenIssuer struct {
+	secret   []byte
+	issuer   string
+	audience string
+	ttl      time.Duration
+}
+
+func NewTokenIssuer(secret []byte, issuer, audience string, ttl time.Duration) (*TokenIssuer, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("JWT_HMAC_SECRET é obrigatório para emitir tokens")
+	}
+	return &TokenIssuer{secret: secret, issuer: issuer, audience: audience, ttl: ttl}, nil
+}
+
+// TTL é a validade dos access tokens emitidos.
+func (t *TokenIssuer) TTL() time.Duration {
+	return t.ttl
+}
+
+// Issue assina um access token para subject. extra acrescenta claims, como
+// papéis ou o ID da sessão.
+func (t *TokenIssuer) Issue(subject string, extra jwt.MapClaims) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub": subject,
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(t.ttl).Unix(),
+		"jti": uuid.NewString(),
+	}
+	if t.issuer != "" {
+		claims["iss"] = t.issuer
+	}
+	if t.audience != "" {
+		claims["aud"] = t.audience
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.secret)
+}
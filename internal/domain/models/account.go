@@ -13,16 +13,26 @@ const (
 	Savings  AccountType = "SAVINGS"
 )
 
+// AccountFrozen é o status de uma conta congelada pela equipe do banco:
+// movimentações e compras ficam bloqueadas até o descongelamento.
+const AccountFrozen = "FROZEN"
+
 type Account struct {
-	ID        uuid.UUID   `json:"id" gorm:"primaryKey;type:uuid"`
-	Type      AccountType `json:"type" gorm:"type:varchar(10)"`
-	Number    string      `json:"number" gorm:"unique"`
-	Status    string      `json:"status"`
-	Balance   float64     `json:"balance"`
-	CreatedAt time.Time   `json:"created_at"`
-	UpdatedAt time.Time   `json:"updated_at"`
+	ID             uuid.UUID   `json:"id" gorm:"primaryKey;type:uuid"`
+	Type           AccountType `json:"type" gorm:"type:varchar(10)"`
+	Number         string      `json:"number" gorm:"unique"`
+	OwnerID        string      `json:"owner_id" gorm:"index"`
+	Status         string      `json:"status"`
+	Balance        float64     `json:"balance"`
+	OverdraftLimit float64     `json:"overdraft_limit"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
 }
 
+// CardActive é o status de um cartão liberado para compras; qualquer outro
+// status bloqueia novas compras.
+const CardActive = "ACTIVE"
+
 type CreditCard struct {
 	ID             uuid.UUID `json:"id" gorm:"primaryKey;type:uuid"`
 	AccountID      uuid.UUID `json:"account_id" gorm:"type:uuid"`
@@ -31,20 +41,40 @@ type CreditCard struct {
 	CVV            string    `json:"-" gorm:"type:varchar(3)"`
 	CreditLimit    float64   `json:"credit_limit"`
 	AvailableLimit float64   `json:"available_limit"`
+	CreditBalance  float64   `json:"credit_balance"`
+	Status         string    `json:"status" gorm:"default:ACTIVE"`
 	StatementDate  int       `json:"statement_date"`
 	DueDate        int       `json:"due_date"`
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
 }
 
+type VirtualCardStatus string
+
+const (
+	VirtualCardActive  VirtualCardStatus = "ACTIVE"
+	VirtualCardUsed    VirtualCardStatus = "USED"
+	VirtualCardDeleted VirtualCardStatus = "DELETED"
+)
+
 type VirtualCard struct {
-	ID             uuid.UUID `json:"id" gorm:"primaryKey;type:uuid"`
-	CreditCardID   uuid.UUID `json:"credit_card_id" gorm:"type:uuid"`
-	Number         string    `json:"number" gorm:"unique"`
-	ExpirationDate time.Time `json:"expiration_date"`
-	CVV            string    `json:"-" gorm:"type:varchar(3)"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID             uuid.UUID         `json:"id" gorm:"primaryKey;type:uuid"`
+	CreditCardID   uuid.UUID         `json:"credit_card_id" gorm:"type:uuid;index"`
+	Number         string            `json:"number" gorm:"unique"`
+	ExpirationDate time.Time         `json:"expiration_date"`
+	CVV            string            `json:"-" gorm:"type:varchar(3)"`
+	SingleUse      bool              `json:"single_use"`
+	SpendingLimit  *float64          `json:"spending_limit,omitempty"`
+	SpentAmount    float64           `json:"spent_amount"`
+	Status         VirtualCardStatus `json:"status" gorm:"type:varchar(10)"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+// VirtualCardResponse expõe o CVV apenas na criação do cartão virtual.
+type VirtualCardResponse struct {
+	VirtualCard
+	CVV string `json:"cvv"`
 }
 
 type PIXKey struct {
@@ -69,23 +99,34 @@ type PIXQRCode struct {
 type TransactionType string
 
 const (
-	Debit        TransactionType = "DEBIT"
-	Credit       TransactionType = "CREDIT"
-	PIXSent      TransactionType = "PIX_SENT"
-	PIXReceived  TransactionType = "PIX_RECEIVED"
-	CardPurchase TransactionType = "CARD_PURCHASE"
-	CardPayment  TransactionType = "CARD_PAYMENT"
+	Debit          TransactionType = "DEBIT"
+	Credit         TransactionType = "CREDIT"
+	PIXSent        TransactionType = "PIX_SENT"
+	PIXReceived    TransactionType = "PIX_RECEIVED"
+	CardPurchase   TransactionType = "CARD_PURCHASE"
+	CardPayment    TransactionType = "CARD_PAYMENT"
+	CardPrepayment TransactionType = "CARD_PREPAYMENT"
 )
 
 type Transaction struct {
-	ID             uuid.UUID       `json:"id" gorm:"primaryKey;type:uuid"`
-	AccountID      uuid.UUID       `json:"account_id" gorm:"type:uuid"`
-	Type           TransactionType `json:"type" gorm:"type:varchar(20)"`
-	Amount         float64         `json:"amount"`
-	Description    string          `json:"description"`
-	DestinationKey *string         `json:"destination_key,omitempty"`
-	CreditCardID   *uuid.UUID      `json:"credit_card_id,omitempty" gorm:"type:uuid"`
-	CreatedAt      time.Time       `json:"created_at"`
+	ID              uuid.UUID        `json:"id" gorm:"primaryKey;type:uuid"`
+	AccountID       uuid.UUID        `json:"account_id" gorm:"type:uuid"`
+	Type            TransactionType  `json:"type" gorm:"type:varchar(20)"`
+	Amount          float64          `json:"amount"`
+	Description     string           `json:"description"`
+	DestinationKey  *string          `json:"destination_key,omitempty"`
+	CreditCardID    *uuid.UUID       `json:"credit_card_id,omitempty" gorm:"type:uuid"`
+	Installments    int              `json:"installments,omitempty"`
+	InterestRate    float64          `json:"interest_rate,omitempty"`
+	RelatedID       *uuid.UUID       `json:"related_id,omitempty" gorm:"type:uuid"`
+	VirtualCardID   *uuid.UUID       `json:"virtual_card_id,omitempty" gorm:"type:uuid;index"`
+	AuthorizationID *uuid.UUID       `json:"authorization_id,omitempty" gorm:"type:uuid"`
+	MerchantName    string           `json:"merchant_name,omitempty"`
+	MCC             string           `json:"mcc,omitempty" gorm:"type:varchar(4)"`
+	Category        MerchantCategory `json:"category,omitempty" gorm:"type:varchar(20)"`
+	Country         string           `json:"country,omitempty" gorm:"type:varchar(2)"`
+	Channel         PurchaseChannel  `json:"channel,omitempty" gorm:"type:varchar(12)"`
+	CreatedAt       time.Time        `json:"created_at"`
 }
 
 type Notification struct {
@@ -107,6 +148,7 @@ type APIResponse struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data,omitempty"`
 	Message string      `json:"message"`
+	Code    string      `json:"code,omitempty"`
 }
 
 type AccountResponse struct {
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey é uma chave de acesso de parceiros e ferramentas internas. Só o
+// hash da chave é guardado; Prefix identifica a chave nas listagens. Scopes
+// limita as operações, Accounts as contas de clientes acessíveis e
+// AllowedIPs, quando preenchido, os IPs ou faixas CIDR de origem.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id" gorm:"primaryKey;type:uuid"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-" gorm:"uniqueIndex"`
+	Scopes     []string   `json:"scopes" gorm:"serializer:json;type:text"`
+	Accounts   []string   `json:"accounts" gorm:"serializer:json;type:text"`
+	AllowedIPs []string   `json:"allowed_ips" gorm:"serializer:json;type:text"`
+	CreatedBy  string     `json:"created_by"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type CreateAPIKeyRequest struct {
+	Nome    string   `json:"nome"`
+	Escopos []string `json:"escopos"`
+	Contas  []string `json:"contas"`
+	IPs     []string `json:"ips"`
+}
+
+// CreatedAPIKey devolve a chave em texto puro, exibida só na criação.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
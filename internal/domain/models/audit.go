@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// AuditEntry é um registro do log de auditoria. Os registros formam uma
+// cadeia: Hash cobre o conteúdo do registro e o Hash do anterior, então
+// alterar ou remover um registro quebra a cadeia a partir dele. Before e
+// After guardam o JSON exato usado no hash.
+type AuditEntry struct {
+	ID         uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
+	ActorID    string    `json:"actor_id" gorm:"index"`
+	ActorRole  string    `json:"actor_role"`
+	IP         string    `json:"ip"`
+	RequestID  string    `json:"request_id" gorm:"index"`
+	AuthMethod string    `json:"auth_method"`
+	Action     string    `json:"action" gorm:"index"`
+	EntityType string    `json:"entity_type" gorm:"index:idx_audit_entity"`
+	EntityID   string    `json:"entity_id" gorm:"index:idx_audit_entity"`
+	Before     string    `json:"before,omitempty" gorm:"type:text"`
+	After      string    `json:"after,omitempty" gorm:"type:text"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+	PrevHash   string    `json:"prev_hash"`
+	Hash       string    `json:"hash" gorm:"uniqueIndex"`
+}
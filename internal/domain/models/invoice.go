@@ -0,0 +1,196 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type InvoiceStatus string
+
+const (
+	InvoiceOpen   InvoiceStatus = "OPEN"
+	InvoiceClosed InvoiceStatus = "CLOSED"
+	InvoicePaid   InvoiceStatus = "PAID"
+)
+
+// Invoice é a fatura mensal de um cartão. ReferenceMonth segue o formato
+// "2006-01" e identifica a fatura junto com o cartão.
+type Invoice struct {
+	ID             uuid.UUID     `json:"id" gorm:"primaryKey;type:uuid"`
+	CreditCardID   uuid.UUID     `json:"credit_card_id" gorm:"type:uuid;uniqueIndex:idx_invoice_card_month"`
+	ReferenceMonth string        `json:"reference_month" gorm:"type:varchar(7);uniqueIndex:idx_invoice_card_month"`
+	ClosingDate    time.Time     `json:"closing_date"`
+	DueDate        time.Time     `json:"due_date"`
+	TotalAmount    float64       `json:"total_amount"`
+	PaidAmount     float64       `json:"paid_amount"`
+	Status         InvoiceStatus `json:"status" gorm:"type:varchar(10)"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+type InstallmentStatus string
+
+const (
+	InstallmentPending InstallmentStatus = "PENDING"
+	InstallmentPaid    InstallmentStatus = "PAID"
+	InstallmentPrepaid InstallmentStatus = "PREPAID"
+)
+
+// Installment é uma parcela de uma compra no cartão, lançada em uma fatura.
+type Installment struct {
+	ID                uuid.UUID         `json:"id" gorm:"primaryKey;type:uuid"`
+	TransactionID     uuid.UUID         `json:"transaction_id" gorm:"type:uuid;index"`
+	CreditCardID      uuid.UUID         `json:"credit_card_id" gorm:"type:uuid;index"`
+	InvoiceID         uuid.UUID         `json:"invoice_id" gorm:"type:uuid;index"`
+	Number            int               `json:"number"`
+	TotalInstallments int               `json:"total_installments"`
+	Amount            float64           `json:"amount"`
+	DueDate           time.Time         `json:"due_date"`
+	Status            InstallmentStatus `json:"status" gorm:"type:varchar(10)"`
+	PaidAt            *time.Time        `json:"paid_at,omitempty"`
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+}
+
+// CardPurchaseRequest reúne os dados de uma compra no cartão.
+// Quando VirtualCardID é informado a compra é debitada do cartão de origem
+// do cartão virtual.
+type CardPurchaseRequest struct {
+	AccountID     uuid.UUID
+	CreditCardID  uuid.UUID
+	VirtualCardID *uuid.UUID
+	Amount        float64
+	Description   string
+	Installments  int
+	WithInterest  bool
+	MerchantName  string
+	MCC           string
+	Country       string
+	Channel       PurchaseChannel
+}
+
+type AuthorizationStatus string
+
+const (
+	AuthorizationPending  AuthorizationStatus = "PENDING"
+	AuthorizationCaptured AuthorizationStatus = "CAPTURED"
+	AuthorizationVoided   AuthorizationStatus = "VOIDED"
+	AuthorizationExpired  AuthorizationStatus = "EXPIRED"
+)
+
+// CardAuthorization é uma pré-autorização que reserva Amount no limite do
+// cartão até ser capturada, cancelada ou expirar.
+type CardAuthorization struct {
+	ID             uuid.UUID           `json:"id" gorm:"primaryKey;type:uuid"`
+	AccountID      uuid.UUID           `json:"account_id" gorm:"type:uuid;index"`
+	CreditCardID   uuid.UUID           `json:"credit_card_id" gorm:"type:uuid;index"`
+	VirtualCardID  *uuid.UUID          `json:"virtual_card_id,omitempty" gorm:"type:uuid"`
+	Amount         float64             `json:"amount"`
+	CapturedAmount float64             `json:"captured_amount"`
+	Description    string              `json:"description"`
+	Installments   int                 `json:"installments"`
+	InterestRate   float64             `json:"interest_rate"`
+	MerchantName   string              `json:"merchant_name,omitempty"`
+	MCC            string              `json:"mcc,omitempty" gorm:"type:varchar(4)"`
+	Category       MerchantCategory    `json:"category,omitempty" gorm:"type:varchar(20)"`
+	Country        string              `json:"country,omitempty" gorm:"type:varchar(2)"`
+	Channel        PurchaseChannel     `json:"channel,omitempty" gorm:"type:varchar(12)"`
+	Status         AuthorizationStatus `json:"status" gorm:"type:varchar(10);index"`
+	ExpiresAt      time.Time           `json:"expires_at" gorm:"index"`
+	CapturedAt     *time.Time          `json:"captured_at,omitempty"`
+	CreatedAt      time.Time           `json:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+}
+
+type EarlyPayoffQuote struct {
+	PurchaseID            uuid.UUID `json:"purchase_id"`
+	RemainingInstallments int       `json:"remaining_installments"`
+	NominalAmount         float64   `json:"nominal_amount"`
+	DiscountedAmount      float64   `json:"discounted_amount"`
+	Discount              float64   `json:"discount"`
+}
+
+// RoundCents arredonda um valor monetário para centavos.
+func RoundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// InstallmentPayment calcula o valor da parcela pela tabela Price. Com taxa
+// zero o valor é apenas dividido entre as parcelas.
+func InstallmentPayment(principal float64, n int, monthlyRate float64) float64 {
+	if n <= 1 {
+		return RoundCents(principal)
+	}
+	if monthlyRate <= 0 {
+		return RoundCents(principal / float64(n))
+	}
+	factor := math.Pow(1+monthlyRate, float64(n))
+	return RoundCents(principal * monthlyRate * factor / (factor - 1))
+}
+
+// SplitInstallments divide total em n parcelas iguais, lançando a diferença
+// de arredondamento na primeira. A conta é feita em centavos inteiros: em
+// float, 4.35*100 é 434.999... e a divisão perderia um centavo.
+func SplitInstallments(total float64, n int) []float64 {
+	if n < 1 {
+		n = 1
+	}
+	cents := int64(math.Round(total * 100))
+	each := cents / int64(n)
+	amounts := make([]float64, n)
+	for i := range amounts {
+		amounts[i] = float64(each) / 100
+	}
+	amounts[0] = float64(cents-each*int64(n-1)) / 100
+	return amounts
+}
+
+// ClosingDay devolve o dia de fechamento da fatura. Cartões sem
+// StatementDate fecham sete dias antes do vencimento.
+func (c *CreditCard) ClosingDay() int {
+	if c.StatementDate > 0 {
+		return c.StatementDate
+	}
+	day := c.DueDay() - 7
+	if day <= 0 {
+		day += 28
+	}
+	return day
+}
+
+// DueDay devolve o dia de vencimento da fatura, padrão dia 10.
+func (c *CreditCard) DueDay() int {
+	if c.DueDate > 0 {
+		return c.DueDate
+	}
+	return 10
+}
+
+// InvoicePeriod devolve o mês de referência, a data de fechamento e a data
+// de vencimento da fatura em que uma compra feita em t é lançada, deslocada
+// em offset meses (usado para as parcelas seguintes).
+func (c *CreditCard) InvoicePeriod(t time.Time, offset int) (string, time.Time, time.Time) {
+	closing := dayInMonth(t.Year(), t.Month(), c.ClosingDay(), t.Location())
+	if !t.Before(closing) {
+		closing = dayInMonth(t.Year(), t.Month()+1, c.ClosingDay(), t.Location())
+	}
+	closing = dayInMonth(closing.Year(), closing.Month()+time.Month(offset), c.ClosingDay(), t.Location())
+
+	due := dayInMonth(closing.Year(), closing.Month(), c.DueDay(), t.Location())
+	if !due.After(closing) {
+		due = dayInMonth(closing.Year(), closing.Month()+1, c.DueDay(), t.Location())
+	}
+	return due.Format("2006-01"), closing, due
+}
+
+// dayInMonth limita o dia ao último dia do mês (ex.: dia 31 em fevereiro).
+func dayInMonth(year int, month time.Month, day int, loc *time.Location) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	last := first.AddDate(0, 1, -1).Day()
+	if day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, loc)
+}
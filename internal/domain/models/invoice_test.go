@@ -0,0 +1,122 @@
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func cents(v float64) int64 {
+	return int64(math.Round(v * 100))
+}
+
+func TestSplitInstallments(t *testing.T) {
+	tests := []struct {
+		total float64
+		n     int
+		want  []float64
+	}{
+		{100, 1, []float64{100}},
+		{100, 3, []float64{33.34, 33.33, 33.33}},
+		{4.35, 3, []float64{1.45, 1.45, 1.45}},
+		{0.29, 2, []float64{0.15, 0.14}},
+		{1.15, 4, []float64{0.31, 0.28, 0.28, 0.28}},
+		{0.05, 6, []float64{0.05, 0, 0, 0, 0, 0}},
+		{1999.99, 12, []float64{166.73, 166.66, 166.66, 166.66, 166.66, 166.66, 166.66, 166.66, 166.66, 166.66, 166.66, 166.66}},
+		{50, 0, []float64{50}},
+	}
+	for _, tt := range tests {
+		got := SplitInstallments(tt.total, tt.n)
+		if len(got) != len(tt.want) {
+			t.Errorf("SplitInstallments(%v, %d) = %v, esperado %v", tt.total, tt.n, got, tt.want)
+			continue
+		}
+		var sum int64
+		for i, amount := range got {
+			if cents(amount) != cents(tt.want[i]) {
+				t.Errorf("SplitInstallments(%v, %d)[%d] = %v, esperado %v", tt.total, tt.n, i, amount, tt.want[i])
+			}
+			sum += cents(amount)
+		}
+		if sum != cents(tt.total) {
+			t.Errorf("SplitInstallments(%v, %d) soma %d centavos, esperado %d", tt.total, tt.n, sum, cents(tt.total))
+		}
+	}
+}
+
+func TestSplitInstallmentsSumsToTotal(t *testing.T) {
+	for c := int64(1); c <= 100000; c += 7 {
+		total := float64(c) / 100
+		for n := 1; n <= 24; n++ {
+			var sum int64
+			for _, amount := range SplitInstallments(total, n) {
+				sum += cents(amount)
+			}
+			if sum != c {
+				t.Fatalf("SplitInstallments(%v, %d) soma %d centavos", total, n, sum)
+			}
+		}
+	}
+}
+
+func TestInstallmentPayment(t *testing.T) {
+	tests := []struct {
+		principal float64
+		n         int
+		rate      float64
+		want      float64
+	}{
+		{1000, 12, 0.01, 88.85},
+		{10000, 24, 0.02, 528.71},
+		{1000, 10, 0.0299, 117.17},
+		{500, 6, 0.0299, 92.27},
+		{1200, 3, 0, 400},
+		{100, 3, 0, 33.33},
+		{250.555, 1, 0.0299, 250.56},
+	}
+	for _, tt := range tests {
+		if got := InstallmentPayment(tt.principal, tt.n, tt.rate); cents(got) != cents(tt.want) {
+			t.Errorf("InstallmentPayment(%v, %d, %v) = %v, esperado %v", tt.principal, tt.n, tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestInvoicePeriod(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	// Fecha no dia 3 e vence no dia 10
+	card := &CreditCard{StatementDate: 3, DueDate: 10}
+	// Fecha no último dia do mês
+	monthEnd := &CreditCard{StatementDate: 31, DueDate: 10}
+	// Sem StatementDate fecha sete dias antes do vencimento: dia 3 - 7 + 28 = 24
+	earlyDue := &CreditCard{DueDate: 3}
+
+	tests := []struct {
+		name        string
+		card        *CreditCard
+		t           time.Time
+		offset      int
+		wantMonth   string
+		wantClosing time.Time
+		wantDue     time.Time
+	}{
+		{"antes do fechamento", card, date(2024, 5, 2).Add(23 * time.Hour), 0, "2024-05", date(2024, 5, 3), date(2024, 5, 10)},
+		{"no dia do fechamento", card, date(2024, 5, 3).Add(time.Hour), 0, "2024-06", date(2024, 6, 3), date(2024, 6, 10)},
+		{"depois do fechamento", card, date(2024, 5, 20), 0, "2024-06", date(2024, 6, 3), date(2024, 6, 10)},
+		{"virada do ano", card, date(2024, 12, 15), 0, "2025-01", date(2025, 1, 3), date(2025, 1, 10)},
+		{"parcela na virada do ano", card, date(2024, 11, 15), 2, "2025-02", date(2025, 2, 3), date(2025, 2, 10)},
+		{"parcelas atravessando o ano", card, date(2024, 10, 1), 5, "2025-03", date(2025, 3, 3), date(2025, 3, 10)},
+		{"fechamento no dia 31 em fevereiro", monthEnd, date(2024, 2, 10), 0, "2024-03", date(2024, 2, 29), date(2024, 3, 10)},
+		{"fechamento no dia 31 na virada do ano", monthEnd, date(2024, 12, 31).Add(10 * time.Hour), 0, "2025-02", date(2025, 1, 31), date(2025, 2, 10)},
+		{"fechamento padrão antes do vencimento", earlyDue, date(2024, 12, 28), 0, "2025-02", date(2025, 1, 24), date(2025, 2, 3)},
+	}
+	for _, tt := range tests {
+		month, closing, due := tt.card.InvoicePeriod(tt.t, tt.offset)
+		if month != tt.wantMonth || !closing.Equal(tt.wantClosing) || !due.Equal(tt.wantDue) {
+			t.Errorf("%s: InvoicePeriod = %s, %s, %s; esperado %s, %s, %s", tt.name,
+				month, closing.Format("2006-01-02"), due.Format("2006-01-02"),
+				tt.wantMonth, tt.wantClosing.Format("2006-01-02"), tt.wantDue.Format("2006-01-02"))
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PurchaseChannel string
+
+const (
+	ChannelOnline      PurchaseChannel = "ONLINE"
+	ChannelContactless PurchaseChannel = "CONTACTLESS"
+	ChannelChip        PurchaseChannel = "CHIP"
+)
+
+// HomeCountry é o país do banco; compras em outros países são internacionais.
+const HomeCountry = "BR"
+
+type MerchantCategory string
+
+const (
+	CategoryGambling      MerchantCategory = "GAMBLING"
+	CategoryTravel        MerchantCategory = "TRAVEL"
+	CategoryLodging       MerchantCategory = "LODGING"
+	CategoryFuel          MerchantCategory = "FUEL"
+	CategoryGrocery       MerchantCategory = "GROCERY"
+	CategoryRestaurants   MerchantCategory = "RESTAURANTS"
+	CategoryEntertainment MerchantCategory = "ENTERTAINMENT"
+	CategoryDigitalGoods  MerchantCategory = "DIGITAL_GOODS"
+	CategoryCash          MerchantCategory = "CASH"
+	CategoryOther         MerchantCategory = "OTHER"
+)
+
+// mccRanges associa faixas de MCC (ISO 18245) às categorias usadas nos
+// controles do cartão. Faixas mais específicas vêm primeiro.
+var mccRanges = []struct {
+	from, to int
+	category MerchantCategory
+}{
+	{7800, 7802, CategoryGambling},
+	{7995, 7995, CategoryGambling},
+	{3501, 3999, CategoryLodging},
+	{7011, 7011, CategoryLodging},
+	{3000, 3500, CategoryTravel},
+	{4111, 4131, CategoryTravel},
+	{4411, 4411, CategoryTravel},
+	{4511, 4511, CategoryTravel},
+	{4722, 4722, CategoryTravel},
+	{5541, 5542, CategoryFuel},
+	{5172, 5172, CategoryFuel},
+	{5411, 5411, CategoryGrocery},
+	{5422, 5499, CategoryGrocery},
+	{5812, 5814, CategoryRestaurants},
+	{7832, 7841, CategoryEntertainment},
+	{7900, 7999, CategoryEntertainment},
+	{5815, 5818, CategoryDigitalGoods},
+	{6010, 6012, CategoryCash},
+	{6051, 6051, CategoryCash},
+}
+
+// CategoryForMCC devolve a categoria de um MCC, ou OTHER se desconhecido.
+func CategoryForMCC(mcc string) MerchantCategory {
+	code, err := strconv.Atoi(mcc)
+	if err != nil {
+		return CategoryOther
+	}
+	for _, r := range mccRanges {
+		if code >= r.from && code <= r.to {
+			return r.category
+		}
+	}
+	return CategoryOther
+}
+
+// Códigos de recusa devolvidos quando uma compra viola um controle do cartão.
+const (
+	DeclineCategoryBlocked       = "CATEGORY_BLOCKED"
+	DeclineOnlineDisabled        = "ONLINE_DISABLED"
+	DeclineInternationalDisabled = "INTERNATIONAL_DISABLED"
+	DeclineCategoryLimitExceeded = "CATEGORY_LIMIT_EXCEEDED"
+)
+
+// CardControls guarda os controles de uso definidos pelo portador do cartão.
+// CategoryLimits limita o gasto mensal por categoria.
+type CardControls struct {
+	CreditCardID          uuid.UUID                    `json:"credit_card_id" gorm:"primaryKey;type:uuid"`
+	BlockedCategories     []MerchantCategory           `json:"blocked_categories" gorm:"serializer:json"`
+	OnlineDisabled        bool                         `json:"online_disabled"`
+	InternationalDisabled bool                         `json:"international_disabled"`
+	CategoryLimits        map[MerchantCategory]float64 `json:"category_limits" gorm:"serializer:json"`
+	CreatedAt             time.Time                    `json:"created_at"`
+	UpdatedAt             time.Time                    `json:"updated_at"`
+}
+
+// Check devolve o código de recusa da compra, ou vazio se ela é permitida.
+// monthSpent é o gasto do cartão na categoria no mês corrente. Com compras
+// internacionais desativadas, compra sem país informado é recusada, já que
+// não há como saber se é nacional.
+func (c *CardControls) Check(category MerchantCategory, channel PurchaseChannel, country string, amount, monthSpent float64) string {
+	for _, blocked := range c.BlockedCategories {
+		if blocked == category {
+			return DeclineCategoryBlocked
+		}
+	}
+	if c.OnlineDisabled && channel == ChannelOnline {
+		return DeclineOnlineDisabled
+	}
+	if c.InternationalDisabled && country != HomeCountry {
+		return DeclineInternationalDisabled
+	}
+	if limit, ok := c.CategoryLimits[category]; ok && monthSpent+amount > limit {
+		return DeclineCategoryLimitExceeded
+	}
+	return ""
+}
@@ -0,0 +1,37 @@
+package models
+
+import "testing"
+
+func TestCardControlsCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		controls   CardControls
+		category   MerchantCategory
+		channel    PurchaseChannel
+		country    string
+		amount     float64
+		monthSpent float64
+		want       string
+	}{
+		{"sem controles", CardControls{}, CategoryGambling, ChannelOnline, "US", 500, 0, ""},
+		{"categoria bloqueada", CardControls{BlockedCategories: []MerchantCategory{CategoryTravel, CategoryGambling}}, CategoryGambling, ChannelChip, HomeCountry, 10, 0, DeclineCategoryBlocked},
+		{"outra categoria liberada", CardControls{BlockedCategories: []MerchantCategory{CategoryGambling}}, CategoryGrocery, ChannelChip, HomeCountry, 10, 0, ""},
+		{"online desativado", CardControls{OnlineDisabled: true}, CategoryGrocery, ChannelOnline, HomeCountry, 10, 0, DeclineOnlineDisabled},
+		{"online desativado, compra presencial", CardControls{OnlineDisabled: true}, CategoryGrocery, ChannelContactless, HomeCountry, 10, 0, ""},
+		{"internacional desativado", CardControls{InternationalDisabled: true}, CategoryGrocery, ChannelChip, "US", 10, 0, DeclineInternationalDisabled},
+		{"internacional desativado, compra nacional", CardControls{InternationalDisabled: true}, CategoryGrocery, ChannelChip, HomeCountry, 10, 0, ""},
+		{"internacional desativado, sem país", CardControls{InternationalDisabled: true}, CategoryGrocery, ChannelOnline, "", 10, 0, DeclineInternationalDisabled},
+		{"internacional liberado, sem país", CardControls{}, CategoryGrocery, ChannelOnline, "", 10, 0, ""},
+		{"compra única acima do teto", CardControls{CategoryLimits: map[MerchantCategory]float64{CategoryRestaurants: 300}}, CategoryRestaurants, ChannelChip, HomeCountry, 300.01, 0, DeclineCategoryLimitExceeded},
+		{"compra única no teto", CardControls{CategoryLimits: map[MerchantCategory]float64{CategoryRestaurants: 300}}, CategoryRestaurants, ChannelChip, HomeCountry, 300, 0, ""},
+		{"teto mensal estourado pelo acumulado", CardControls{CategoryLimits: map[MerchantCategory]float64{CategoryRestaurants: 300}}, CategoryRestaurants, ChannelChip, HomeCountry, 60, 250, DeclineCategoryLimitExceeded},
+		{"teto mensal ainda com saldo", CardControls{CategoryLimits: map[MerchantCategory]float64{CategoryRestaurants: 300}}, CategoryRestaurants, ChannelChip, HomeCountry, 50, 250, ""},
+		{"teto de outra categoria", CardControls{CategoryLimits: map[MerchantCategory]float64{CategoryRestaurants: 300}}, CategoryFuel, ChannelChip, HomeCountry, 1000, 5000, ""},
+		{"bloqueio vence o teto", CardControls{BlockedCategories: []MerchantCategory{CategoryCash}, CategoryLimits: map[MerchantCategory]float64{CategoryCash: 100}}, CategoryCash, ChannelChip, HomeCountry, 500, 0, DeclineCategoryBlocked},
+	}
+	for _, tt := range tests {
+		if got := tt.controls.Check(tt.category, tt.channel, tt.country, tt.amount, tt.monthSpent); got != tt.want {
+			t.Errorf("%s: Check = %q, esperado %q", tt.name, got, tt.want)
+		}
+	}
+}
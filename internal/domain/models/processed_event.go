@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProcessedEvent registra um evento Kafka já aplicado. É gravado na mesma
+// transação dos efeitos do evento, então uma reentrega encontra o registro e
+// é ignorada.
+type ProcessedEvent struct {
+	EventID     uuid.UUID `gorm:"primaryKey;type:uuid"`
+	Topic       string    `gorm:"not null"`
+	ProcessedAt time.Time `gorm:"index"`
+}
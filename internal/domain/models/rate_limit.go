@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// RateLimitBucket é o estado de um token bucket do limitador de requisições
+// quando os limites são compartilhados entre réplicas.
+type RateLimitBucket struct {
+	Key       string    `gorm:"primaryKey"`
+	Tokens    float64   `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// User é o cliente que faz login no banco. As contas criadas por ele guardam
+// o ID do usuário em Account.OwnerID, que também é o sub dos tokens emitidos.
+type User struct {
+	ID           uuid.UUID  `json:"id" gorm:"primaryKey;type:uuid"`
+	Email        string     `json:"email" gorm:"uniqueIndex"`
+	Name         string     `json:"name"`
+	Role         string     `json:"role" gorm:"default:customer"`
+	PasswordHash string     `json:"-"`
+	FailedLogins int        `json:"-"`
+	LockedUntil  *time.Time `json:"-"`
+	// TOTPSecret é o segredo TOTP cifrado. Ele é gravado no cadastro e só
+	// passa a valer no login depois de confirmado com um código.
+	TOTPSecret   string    `json:"-"`
+	TOTPEnabled  bool      `json:"totp_enabled"`
+	TOTPLastStep int64     `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// RecoveryCode é um código de uso único que substitui o TOTP quando o
+// usuário perde o autenticador. Só o hash é guardado.
+type RecoveryCode struct {
+	ID        uuid.UUID  `json:"id" gorm:"primaryKey;type:uuid"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	CodeHash  string     `json:"-" gorm:"uniqueIndex"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// KnownDevice é um dispositivo, identificado pelo cabeçalho X-Device-ID, que
+// já concluiu um login. Logins de dispositivos novos exigem o TOTP.
+type KnownDevice struct {
+	ID         uuid.UUID `json:"id" gorm:"primaryKey;type:uuid"`
+	UserID     uuid.UUID `json:"user_id" gorm:"type:uuid;uniqueIndex:idx_known_device"`
+	DeviceHash string    `json:"-" gorm:"uniqueIndex:idx_known_device"`
+	UserAgent  string    `json:"user_agent"`
+	LastIP     string    `json:"last_ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// TOTPEnrollment é a resposta do cadastro do TOTP.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// RefreshToken guarda o hash de um refresh token. Cada renovação revoga o
+// token usado e emite outro na mesma família; reutilizar um token já
+// revogado revoga a família inteira.
+type RefreshToken struct {
+	ID         uuid.UUID  `json:"id" gorm:"primaryKey;type:uuid"`
+	UserID     uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	FamilyID   uuid.UUID  `json:"family_id" gorm:"type:uuid;index"`
+	TokenHash  string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	ReplacedBy *uuid.UUID `json:"replaced_by,omitempty" gorm:"type:uuid"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Session é um login de um usuário em um dispositivo. O ID da sessão é a
+// família dos refresh tokens emitidos para ela e vai na claim sid dos access
+// tokens, então encerrar a sessão invalida os tokens já emitidos.
+type Session struct {
+	ID         uuid.UUID  `json:"id" gorm:"primaryKey;type:uuid"`
+	UserID     uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	DeviceName string     `json:"device_name"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" gorm:"index"`
+	Current    bool       `json:"current" gorm:"-"`
+}
+
+// TransactionPIN guarda o hash do PIN de transação do usuário, exigido em
+// operações sensíveis. Depois de erros seguidos o PIN fica bloqueado até ser
+// redefinido com a senha.
+type TransactionPIN struct {
+	UserID         uuid.UUID  `json:"user_id" gorm:"primaryKey;type:uuid"`
+	PINHash        string     `json:"-"`
+	FailedAttempts int        `json:"failed_attempts"`
+	BlockedAt      *time.Time `json:"blocked_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// TokenPair é a resposta de login e de renovação de tokens.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// CustomerSummary é o resultado da busca de clientes no back-office.
+type CustomerSummary struct {
+	User     User      `json:"user"`
+	Accounts []Account `json:"accounts"`
+}
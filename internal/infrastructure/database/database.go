@@ -13,6 +13,20 @@ import (
 
 var DB *gorm.DB
 
+var auditAppendOnlySQL = []string{
+	`CREATE OR REPLACE FUNCTION audit_entries_append_only() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'audit_entries aceita apenas inclusões';
+	END;
+	$$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS audit_entries_no_change ON audit_entries`,
+	`CREATE TRIGGER audit_entries_no_change BEFORE UPDATE OR DELETE ON audit_entries
+		FOR EACH ROW EXECUTE FUNCTION audit_entries_append_only()`,
+	`DROP TRIGGER IF EXISTS audit_entries_no_truncate ON audit_entries`,
+	`CREATE TRIGGER audit_entries_no_truncate BEFORE TRUNCATE ON audit_entries
+		FOR EACH STATEMENT EXECUTE FUNCTION audit_entries_append_only()`,
+}
+
 func InitDB(db *gorm.DB) error {
 	DB = db
 
@@ -23,11 +37,33 @@ func InitDB(db *gorm.DB) error {
 		&models.Notification{},
 		&models.PIXKey{},
 		&models.CreditCard{},
+		&models.VirtualCard{},
+		&models.Invoice{},
+		&models.Installment{},
+		&models.CardAuthorization{},
+		&models.CardControls{},
+		&models.User{},
+		&models.RefreshToken{},
+		&models.Session{},
+		&models.TransactionPIN{},
+		&models.RecoveryCode{},
+		&models.KnownDevice{},
+		&models.APIKey{},
+		&models.RateLimitBucket{},
+		&models.AuditEntry{},
+		&models.ProcessedEvent{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %v", err)
 	}
 
+	// O log de auditoria só aceita inclusões
+	for _, stmt := range auditAppendOnlySQL {
+		if err := DB.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to protect audit log: %v", err)
+		}
+	}
+
 	log.Println("Successfully initialized database schema")
 	return nil
 }
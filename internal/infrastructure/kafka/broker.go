@@ -0,0 +1,99 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Message é uma mensagem lida de um tópico.
+type Message struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+	Timestamp time.Time
+}
+
+// Publisher publica mensagens nos tópicos. PublishMessage serializa as
+// mensagens de messages.go; Publish envia bytes já serializados, como no
+// encaminhamento para retentativa e DLQ.
+type Publisher interface {
+	PublishMessage(topic string, key string, message interface{}) error
+	Publish(topic string, key []byte, value []byte, headers map[string]string) error
+	Close() error
+}
+
+// MessageHandler processa uma mensagem. Um erro interrompe a entrega da
+// partição sem confirmar a mensagem, que volta na próxima sessão do grupo.
+type MessageHandler func(ctx context.Context, msg *Message) error
+
+// Subscriber entrega as mensagens dos tópicos a um handler como membro de um
+// grupo de consumo: as partições são divididas entre os membros do grupo e
+// cada partição é entregue em ordem. Subscribe bloqueia até o contexto ser
+// cancelado ou o Subscriber ser fechado.
+type Subscriber interface {
+	Subscribe(ctx context.Context, group string, topics []string, handler MessageHandler) error
+	Close() error
+}
+
+// Implementações do broker aceitas em MESSAGE_BROKER.
+const (
+	BrokerKafka  = "kafka"
+	BrokerMemory = "memory"
+)
+
+// NewBroker cria o Publisher e o Subscriber do tipo informado. O broker em
+// memória dispensa o Kafka e serve para desenvolvimento local e testes, mas
+// as mensagens se perdem quando o processo termina.
+func NewBroker(kind string, client ClientConfig, cfg ConsumerConfig, codecs Codecs) (Publisher, Subscriber, error) {
+	switch kind {
+	case "", BrokerKafka:
+		producer, err := NewProducer(client, codecs)
+		if err != nil {
+			return nil, nil, err
+		}
+		subscriber, err := NewGroupSubscriber(client, cfg)
+		if err != nil {
+			producer.Close()
+			return nil, nil, err
+		}
+		return producer, subscriber, nil
+	case BrokerMemory:
+		broker := NewMemoryBroker(memoryPartitions, cfg.InitialOffset, codecs)
+		return broker, broker, nil
+	default:
+		return nil, nil, fmt.Errorf("broker desconhecido: %s (use %s ou %s)", kind, BrokerKafka, BrokerMemory)
+	}
+}
+
+// encodeMessage confere a chave de partição e serializa a mensagem. As
+// mensagens de messages.go vão dentro de um Envelope, com o codec do tópico;
+// o content-type devolvido segue no cabeçalho da mensagem.
+func encodeMessage(topic string, key string, message interface{}, producer string, codecs Codecs) ([]byte, string, error) {
+	if key == "" {
+		return nil, "", fmt.Errorf("mensagem para o tópico %s sem chave de partição", topic)
+	}
+	if keyed, ok := message.(partitionKeyer); ok && keyed.PartitionKey() != key {
+		return nil, "", fmt.Errorf("chave de partição %q não corresponde à mensagem (%q)", key, keyed.PartitionKey())
+	}
+
+	event, ok := message.(eventMessage)
+	if !ok {
+		data, err := json.Marshal(message)
+		return data, ContentTypeJSON, err
+	}
+	envelope, payload, err := newEnvelope(event, producer)
+	if err != nil {
+		return nil, "", fmt.Errorf("mensagem para o tópico %s: %v", topic, err)
+	}
+	codec := codecs.For(topic)
+	data, err := codec.Marshal(envelope, payload)
+	if err != nil {
+		return nil, "", fmt.Errorf("mensagem para o tópico %s: %v", topic, err)
+	}
+	return data, codec.ContentType(), nil
+}
@@ -0,0 +1,135 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/red-velvet-workspace/banco-digital/internal/audit"
+	"github.com/red-velvet-workspace/banco-digital/internal/domain/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// authorizeCardPurchase reserva o valor da compra no limite do cartão e
+// registra a pré-autorização com o mesmo ID da transação recebida.
+func (p *Projector) authorizeCardPurchase(eventID uuid.UUID, transaction models.Transaction, expiresAt time.Time, actor audit.Actor) error {
+	if transaction.CreditCardID == nil {
+		return fmt.Errorf("autorização %s sem cartão", transaction.ID)
+	}
+
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		if fresh, err := claimEvent(tx, TopicTransactions, eventID); err != nil || !fresh {
+			return err
+		}
+
+		result := tx.Model(&models.CreditCard{}).
+			Where("id = ? AND available_limit >= ?", *transaction.CreditCardID, transaction.Amount).
+			UpdateColumn("available_limit", gorm.Expr("available_limit - ?", transaction.Amount))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return rejected("Limite insuficiente para pré-autorização")
+		}
+
+		authorization := models.CardAuthorization{
+			ID:            transaction.ID,
+			AccountID:     transaction.AccountID,
+			CreditCardID:  *transaction.CreditCardID,
+			VirtualCardID: transaction.VirtualCardID,
+			Amount:        transaction.Amount,
+			Description:   transaction.Description,
+			Installments:  transaction.Installments,
+			InterestRate:  transaction.InterestRate,
+			MerchantName:  transaction.MerchantName,
+			MCC:           transaction.MCC,
+			Category:      transaction.Category,
+			Country:       transaction.Country,
+			Channel:       transaction.Channel,
+			Status:        models.AuthorizationPending,
+			ExpiresAt:     expiresAt,
+			CreatedAt:     transaction.CreatedAt,
+			UpdatedAt:     time.Now(),
+		}
+		if err := tx.Create(&authorization).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, actor, "card_authorization.create", "card_authorization", authorization.ID.String(), nil, authorization)
+	})
+
+	var rejection transactionRejection
+	if errors.As(err, &rejection) {
+		return p.rejectTransaction(eventID, transaction, string(rejection))
+	}
+	return err
+}
+
+// releaseAuthorization devolve ao limite o valor de uma pré-autorização
+// pendente, marcando-a como cancelada ou expirada. Autorizações que já
+// saíram de PENDING são ignoradas, o que torna a operação idempotente.
+func (p *Projector) releaseAuthorization(eventID uuid.UUID, authorizationID uuid.UUID, status models.AuthorizationStatus, actor audit.Actor) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		if fresh, err := claimEvent(tx, TopicTransactions, eventID); err != nil || !fresh {
+			return err
+		}
+
+		var authorization models.CardAuthorization
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&authorization, "id = ? AND status = ?", authorizationID, models.AuthorizationPending).Error
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.CreditCard{}).Where("id = ?", authorization.CreditCardID).
+			UpdateColumn("available_limit", gorm.Expr("available_limit + ?", authorization.Amount)).Error; err != nil {
+			return err
+		}
+		before := authorization
+		if err := tx.Model(&authorization).Updates(map[string]interface{}{
+			"status":     status,
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		action := "card_authorization." + strings.ToLower(string(status))
+		return audit.Record(tx, actor, action, "card_authorization", authorization.ID.String(), before, authorization)
+	})
+}
+
+// captureAuthorization encerra a pré-autorização capturada pela compra e
+// devolve o valor reservado ao limite; a compra em seguida reserva apenas
+// o valor capturado.
+func captureAuthorization(tx *gorm.DB, transaction models.Transaction) error {
+	var authorization models.CardAuthorization
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&authorization, "id = ?", *transaction.AuthorizationID).Error
+	if err != nil {
+		return err
+	}
+	if authorization.Status != models.AuthorizationPending {
+		return errAuthorizationNotPending
+	}
+	if transaction.Amount > authorization.Amount {
+		return fmt.Errorf("captura de %.2f excede a autorização %s de %.2f",
+			transaction.Amount, authorization.ID, authorization.Amount)
+	}
+
+	if err := tx.Model(&models.CreditCard{}).Where("id = ?", authorization.CreditCardID).
+		UpdateColumn("available_limit", gorm.Expr("available_limit + ?", authorization.Amount)).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return tx.Model(&authorization).Updates(map[string]interface{}{
+		"status":          models.AuthorizationCaptured,
+		"captured_amount": transaction.Amount,
+		"captured_at":     now,
+		"updated_at":      now,
+	}).Error
+}
@@ -0,0 +1,182 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/xdg-go/scram"
+)
+
+// Mecanismos SASL aceitos em KAFKA_SASL_MECHANISM
+const (
+	SASLPlain       = "PLAIN"
+	SASLScramSHA256 = "SCRAM-SHA-256"
+	SASLScramSHA512 = "SCRAM-SHA-512"
+)
+
+// ClientConfig reúne o que todo cliente Kafka do serviço precisa para se
+// conectar: brokers, identificação e segurança da conexão.
+type ClientConfig struct {
+	Brokers  []string
+	ClientID string
+
+	TLS                   bool
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSInsecureSkipVerify bool
+
+	SASLMechanism string
+	SASLUsername  string
+	SASLPassword  string
+}
+
+// LoadClientConfig lê KAFKA_BROKERS (separados por vírgula, padrão
+// kafka:9092), KAFKA_CLIENT_ID (padrão banco-digital), as variáveis
+// KAFKA_TLS_* e as KAFKA_SASL_*.
+func LoadClientConfig() (ClientConfig, error) {
+	cfg := ClientConfig{
+		Brokers:  []string{"kafka:9092"},
+		ClientID: "banco-digital",
+	}
+	if v := os.Getenv("KAFKA_BROKERS"); v != "" {
+		cfg.Brokers = nil
+		for _, broker := range strings.Split(v, ",") {
+			if broker = strings.TrimSpace(broker); broker != "" {
+				cfg.Brokers = append(cfg.Brokers, broker)
+			}
+		}
+		if len(cfg.Brokers) == 0 {
+			return cfg, fmt.Errorf("KAFKA_BROKERS inválido: %q", v)
+		}
+	}
+	if v := os.Getenv("KAFKA_CLIENT_ID"); v != "" {
+		cfg.ClientID = v
+	}
+
+	flags := map[string]*bool{
+		"KAFKA_TLS_ENABLED":              &cfg.TLS,
+		"KAFKA_TLS_INSECURE_SKIP_VERIFY": &cfg.TLSInsecureSkipVerify,
+	}
+	for name, dst := range flags {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("%s inválido: %v", name, err)
+		}
+		*dst = b
+	}
+	cfg.TLSCAFile = os.Getenv("KAFKA_TLS_CA_FILE")
+	cfg.TLSCertFile = os.Getenv("KAFKA_TLS_CERT_FILE")
+	cfg.TLSKeyFile = os.Getenv("KAFKA_TLS_KEY_FILE")
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return cfg, fmt.Errorf("KAFKA_TLS_CERT_FILE e KAFKA_TLS_KEY_FILE devem ser informados juntos")
+	}
+
+	cfg.SASLMechanism = strings.ToUpper(os.Getenv("KAFKA_SASL_MECHANISM"))
+	cfg.SASLUsername = os.Getenv("KAFKA_SASL_USERNAME")
+	cfg.SASLPassword = os.Getenv("KAFKA_SASL_PASSWORD")
+	switch cfg.SASLMechanism {
+	case "":
+	case SASLPlain, SASLScramSHA256, SASLScramSHA512:
+		if cfg.SASLUsername == "" || cfg.SASLPassword == "" {
+			return cfg, fmt.Errorf("KAFKA_SASL_USERNAME e KAFKA_SASL_PASSWORD são obrigatórios com KAFKA_SASL_MECHANISM=%s", cfg.SASLMechanism)
+		}
+	default:
+		return cfg, fmt.Errorf("KAFKA_SASL_MECHANISM inválido: %s (use %s, %s ou %s)", cfg.SASLMechanism, SASLPlain, SASLScramSHA256, SASLScramSHA512)
+	}
+	return cfg, nil
+}
+
+// saramaConfig monta a configuração base do sarama com a identificação e a
+// segurança da conexão. Cada cliente completa o resto.
+func (c ClientConfig) saramaConfig() (*sarama.Config, error) {
+	config := sarama.NewConfig()
+	if c.ClientID != "" {
+		config.ClientID = c.ClientID
+	}
+
+	if c.TLS {
+		tlsConfig, err := c.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		config.Net.TLS.Enable = true
+		config.Net.TLS.Config = tlsConfig
+	}
+
+	if c.SASLMechanism != "" {
+		config.Net.SASL.Enable = true
+		config.Net.SASL.User = c.SASLUsername
+		config.Net.SASL.Password = c.SASLPassword
+		config.Net.SASL.Mechanism = sarama.SASLMechanism(c.SASLMechanism)
+		switch c.SASLMechanism {
+		case SASLScramSHA256:
+			config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+				return &scramClient{hash: scram.SHA256}
+			}
+		case SASLScramSHA512:
+			config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+				return &scramClient{hash: scram.SHA512}
+			}
+		}
+	}
+	return config, nil
+}
+
+func (c ClientConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.TLSInsecureSkipVerify,
+	}
+	if c.TLSCAFile != "" {
+		pem, err := os.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler KAFKA_TLS_CA_FILE: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("KAFKA_TLS_CA_FILE sem certificados válidos")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler o certificado do cliente Kafka: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// scramClient adapta o xdg-go/scram à interface de SCRAM do sarama.
+type scramClient struct {
+	hash         scram.HashGeneratorFcn
+	conversation *scram.ClientConversation
+}
+
+func (s *scramClient) Begin(userName, password, authzID string) error {
+	client, err := s.hash.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	s.conversation = client.NewConversation()
+	return nil
+}
+
+func (s *scramClient) Step(challenge string) (string, error) {
+	return s.conversation.Step(challenge)
+}
+
+func (s *scramClient) Done() bool {
+	return s.conversation.Done()
+}
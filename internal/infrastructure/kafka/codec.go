@@ -0,0 +1,141 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Cabeçalho com o content-type da mensagem e os formatos aceitos.
+// Mensagens sem o cabeçalho são lidas como JSON.
+const (
+	HeaderContentType   = "content-type"
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// Codec serializa o envelope e o payload dos eventos.
+type Codec interface {
+	ContentType() string
+	// Marshal serializa o envelope com o payload de events.go
+	Marshal(envelope Envelope, payload interface{}) ([]byte, error)
+	// Unmarshal lê o envelope; o payload continua serializado em
+	// Envelope.Payload até UnmarshalPayload
+	Unmarshal(data []byte) (Envelope, error)
+	UnmarshalPayload(envelope Envelope, payload interface{}) error
+}
+
+var (
+	JSONCodec     Codec = jsonCodec{}
+	ProtobufCodec Codec = protobufCodec{}
+)
+
+// codecFor escolhe o codec pelo content-type da mensagem recebida, então os
+// consumidores leem os dois formatos independentemente do que é publicado.
+func codecFor(contentType string) (Codec, error) {
+	switch contentType {
+	case "", ContentTypeJSON:
+		return JSONCodec, nil
+	case ContentTypeProtobuf:
+		return ProtobufCodec, nil
+	default:
+		return nil, fmt.Errorf("content-type não suportado: %s", contentType)
+	}
+}
+
+// Codecs escolhe o codec de publicação de cada tópico, para os tópicos
+// migrarem de formato um de cada vez. O valor zero publica tudo em JSON.
+type Codecs struct {
+	Default Codec
+	Topics  map[string]Codec
+}
+
+func (c Codecs) For(topic string) Codec {
+	if codec, ok := c.Topics[topic]; ok {
+		return codec
+	}
+	if c.Default != nil {
+		return c.Default
+	}
+	return JSONCodec
+}
+
+// LoadCodecs lê KAFKA_CODEC (json, o padrão, ou protobuf) e
+// KAFKA_TOPIC_CODECS, com exceções por tópico no formato
+// "transactions=protobuf,accounts=json".
+func LoadCodecs() (Codecs, error) {
+	var codecs Codecs
+	if v := os.Getenv("KAFKA_CODEC"); v != "" {
+		codec, err := codecNamed(v)
+		if err != nil {
+			return codecs, fmt.Errorf("KAFKA_CODEC inválido: %v", err)
+		}
+		codecs.Default = codec
+	}
+
+	if v := os.Getenv("KAFKA_TOPIC_CODECS"); v != "" {
+		codecs.Topics = make(map[string]Codec)
+		for _, entry := range strings.Split(v, ",") {
+			topic, name, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if !ok || topic == "" {
+				return codecs, fmt.Errorf("KAFKA_TOPIC_CODECS inválido: %s (use tópico=codec)", entry)
+			}
+			codec, err := codecNamed(name)
+			if err != nil {
+				return codecs, fmt.Errorf("KAFKA_TOPIC_CODECS inválido: %v", err)
+			}
+			codecs.Topics[topic] = codec
+		}
+	}
+	return codecs, nil
+}
+
+func codecNamed(name string) (Codec, error) {
+	switch name {
+	case "json":
+		return JSONCodec, nil
+	case "protobuf":
+		return ProtobufCodec, nil
+	default:
+		return nil, fmt.Errorf("codec desconhecido: %s (use json ou protobuf)", name)
+	}
+}
+
+// jsonCodec publica o envelope como objeto JSON com o payload aninhado.
+type jsonCodec struct{}
+
+type jsonEnvelope struct {
+	Envelope
+	Payload json.RawMessage `json:"payload"`
+}
+
+func (jsonCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (jsonCodec) Marshal(envelope Envelope, payload interface{}) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonEnvelope{Envelope: envelope, Payload: data})
+}
+
+// Unmarshal aceita também a mensagem da versão 1, sem envelope, que volta
+// com SchemaVersion 1 e o corpo inteiro como payload.
+func (jsonCodec) Unmarshal(data []byte) (Envelope, error) {
+	var envelope jsonEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return Envelope{}, err
+	}
+	if envelope.SchemaVersion == 0 {
+		return Envelope{SchemaVersion: schemaV1, Payload: data}, nil
+	}
+	envelope.Envelope.Payload = envelope.Payload
+	return envelope.Envelope, nil
+}
+
+func (jsonCodec) UnmarshalPayload(envelope Envelope, payload interface{}) error {
+	return json.Unmarshal(envelope.Payload, payload)
+}
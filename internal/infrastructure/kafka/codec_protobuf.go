@@ -0,0 +1,259 @@
+package kafka
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/red-velvet-workspace/banco-digital/internal/audit"
+	"github.com/red-velvet-workspace/banco-digital/internal/infrastructure/kafka/eventspb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// protobufCodec publica o envelope e o payload com as mensagens de
+// eventspb/events.proto.
+type protobufCodec struct{}
+
+func (protobufCodec) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (protobufCodec) Marshal(envelope Envelope, payload interface{}) ([]byte, error) {
+	message, err := payloadToProto(payload)
+	if err != nil {
+		return nil, err
+	}
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(&eventspb.Envelope{
+		EventId:       envelope.EventID.String(),
+		EventType:     envelope.EventType,
+		SchemaVersion: int32(envelope.SchemaVersion),
+		OccurredAt:    timestamppb.New(envelope.OccurredAt),
+		Producer:      envelope.Producer,
+		CorrelationId: envelope.CorrelationID,
+		CausationId:   envelope.CausationID,
+		Actor: &eventspb.Actor{
+			Id:         envelope.Actor.ID,
+			Role:       envelope.Actor.Role,
+			Ip:         envelope.Actor.IP,
+			RequestId:  envelope.Actor.RequestID,
+			AuthMethod: envelope.Actor.AuthMethod,
+		},
+		Payload: data,
+	})
+}
+
+func (protobufCodec) Unmarshal(data []byte) (Envelope, error) {
+	var pb eventspb.Envelope
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return Envelope{}, err
+	}
+	eventID, err := uuid.Parse(pb.EventId)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("event_id inválido: %v", err)
+	}
+
+	return Envelope{
+		EventID:       eventID,
+		EventType:     pb.EventType,
+		SchemaVersion: int(pb.SchemaVersion),
+		OccurredAt:    pb.OccurredAt.AsTime(),
+		Producer:      pb.Producer,
+		CorrelationID: pb.CorrelationId,
+		CausationID:   pb.CausationId,
+		Actor: audit.Actor{
+			ID:         pb.Actor.GetId(),
+			Role:       pb.Actor.GetRole(),
+			IP:         pb.Actor.GetIp(),
+			RequestID:  pb.Actor.GetRequestId(),
+			AuthMethod: pb.Actor.GetAuthMethod(),
+		},
+		Payload: pb.Payload,
+	}, nil
+}
+
+// UnmarshalPayload recusa IDs malformados: um ID zerado ou perdido faria o
+// evento ser aplicado na entidade errada, em vez de ir para retentativa e DLQ.
+func (protobufCodec) UnmarshalPayload(envelope Envelope, payload interface{}) error {
+	var ids uuidParser
+	switch p := payload.(type) {
+	case *AccountPayload:
+		var pb eventspb.AccountEvent
+		if err := proto.Unmarshal(envelope.Payload, &pb); err != nil {
+			return err
+		}
+		*p = AccountPayload{
+			AccountID: ids.required("account_id", pb.AccountId),
+			Type:      pb.Type,
+			Number:    pb.Number,
+			OwnerID:   pb.OwnerId,
+			Status:    pb.Status,
+			Balance:   pb.Balance,
+			CreatedAt: pb.CreatedAt.AsTime(),
+			UpdatedAt: pb.UpdatedAt.AsTime(),
+		}
+	case *PIXKeyPayload:
+		var pb eventspb.PIXKeyEvent
+		if err := proto.Unmarshal(envelope.Payload, &pb); err != nil {
+			return err
+		}
+		*p = PIXKeyPayload{
+			PIXKeyID:  ids.required("pix_key_id", pb.PixKeyId),
+			AccountID: ids.required("account_id", pb.AccountId),
+			KeyType:   pb.KeyType,
+			Key:       pb.Key,
+			CreatedAt: pb.CreatedAt.AsTime(),
+			UpdatedAt: pb.UpdatedAt.AsTime(),
+		}
+	case *CreditCardPayload:
+		var pb eventspb.CreditCardEvent
+		if err := proto.Unmarshal(envelope.Payload, &pb); err != nil {
+			return err
+		}
+		*p = CreditCardPayload{
+			CreditCardID:   ids.required("credit_card_id", pb.CreditCardId),
+			AccountID:      ids.required("account_id", pb.AccountId),
+			Number:         pb.Number,
+			ExpirationDate: pb.ExpirationDate.AsTime(),
+			CreditLimit:    pb.CreditLimit,
+			AvailableLimit: pb.AvailableLimit,
+			CreditBalance:  pb.CreditBalance,
+			StatementDate:  int(pb.StatementDate),
+			DueDate:        int(pb.DueDate),
+			CreatedAt:      pb.CreatedAt.AsTime(),
+			UpdatedAt:      pb.UpdatedAt.AsTime(),
+		}
+	case *TransactionPayload:
+		var pb eventspb.TransactionEvent
+		if err := proto.Unmarshal(envelope.Payload, &pb); err != nil {
+			return err
+		}
+		*p = TransactionPayload{
+			TransactionID:   ids.required("transaction_id", pb.TransactionId),
+			AccountID:       ids.required("account_id", pb.AccountId),
+			Type:            pb.Type,
+			Amount:          pb.Amount,
+			Description:     pb.Description,
+			DestinationKey:  pb.DestinationKey,
+			CreditCardID:    ids.optional("credit_card_id", pb.CreditCardId),
+			VirtualCardID:   ids.optional("virtual_card_id", pb.VirtualCardId),
+			AuthorizationID: ids.optional("authorization_id", pb.AuthorizationId),
+			RelatedID:       ids.optional("related_id", pb.RelatedId),
+			Installments:    int(pb.Installments),
+			InterestRate:    pb.InterestRate,
+			MerchantName:    pb.MerchantName,
+			MCC:             pb.Mcc,
+			Category:        pb.Category,
+			Country:         pb.Country,
+			Channel:         pb.Channel,
+			CreatedAt:       pb.CreatedAt.AsTime(),
+		}
+		if pb.ExpiresAt != nil {
+			expiresAt := pb.ExpiresAt.AsTime()
+			p.ExpiresAt = &expiresAt
+		}
+	default:
+		return fmt.Errorf("payload sem mensagem Protobuf: %T", payload)
+	}
+	return ids.err
+}
+
+func payloadToProto(payload interface{}) (proto.Message, error) {
+	switch p := payload.(type) {
+	case AccountPayload:
+		return &eventspb.AccountEvent{
+			AccountId: p.AccountID.String(),
+			Type:      p.Type,
+			Number:    p.Number,
+			OwnerId:   p.OwnerID,
+			Status:    p.Status,
+			Balance:   p.Balance,
+			CreatedAt: timestamppb.New(p.CreatedAt),
+			UpdatedAt: timestamppb.New(p.UpdatedAt),
+		}, nil
+	case PIXKeyPayload:
+		return &eventspb.PIXKeyEvent{
+			PixKeyId:  p.PIXKeyID.String(),
+			AccountId: p.AccountID.String(),
+			KeyType:   p.KeyType,
+			Key:       p.Key,
+			CreatedAt: timestamppb.New(p.CreatedAt),
+			UpdatedAt: timestamppb.New(p.UpdatedAt),
+		}, nil
+	case CreditCardPayload:
+		return &eventspb.CreditCardEvent{
+			CreditCardId:   p.CreditCardID.String(),
+			AccountId:      p.AccountID.String(),
+			Number:         p.Number,
+			ExpirationDate: timestamppb.New(p.ExpirationDate),
+			CreditLimit:    p.CreditLimit,
+			AvailableLimit: p.AvailableLimit,
+			CreditBalance:  p.CreditBalance,
+			StatementDate:  int32(p.StatementDate),
+			DueDate:        int32(p.DueDate),
+			CreatedAt:      timestamppb.New(p.CreatedAt),
+			UpdatedAt:      timestamppb.New(p.UpdatedAt),
+		}, nil
+	case TransactionPayload:
+		pb := &eventspb.TransactionEvent{
+			TransactionId:   p.TransactionID.String(),
+			AccountId:       p.AccountID.String(),
+			Type:            p.Type,
+			Amount:          p.Amount,
+			Description:     p.Description,
+			DestinationKey:  p.DestinationKey,
+			CreditCardId:    optionalUUID(p.CreditCardID),
+			VirtualCardId:   optionalUUID(p.VirtualCardID),
+			AuthorizationId: optionalUUID(p.AuthorizationID),
+			RelatedId:       optionalUUID(p.RelatedID),
+			Installments:    int32(p.Installments),
+			InterestRate:    p.InterestRate,
+			MerchantName:    p.MerchantName,
+			Mcc:             p.MCC,
+			Category:        p.Category,
+			Country:         p.Country,
+			Channel:         p.Channel,
+			CreatedAt:       timestamppb.New(p.CreatedAt),
+		}
+		if p.ExpiresAt != nil {
+			pb.ExpiresAt = timestamppb.New(*p.ExpiresAt)
+		}
+		return pb, nil
+	default:
+		return nil, fmt.Errorf("payload sem mensagem Protobuf: %T", payload)
+	}
+}
+
+// uuidParser lê os IDs de um payload e guarda o primeiro erro, para que o
+// payload seja montado de uma vez e conferido no fim.
+type uuidParser struct {
+	err error
+}
+
+func (p *uuidParser) required(field, s string) uuid.UUID {
+	id, err := uuid.Parse(s)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("%s inválido: %q", field, s)
+	}
+	return id
+}
+
+func (p *uuidParser) optional(field string, s *string) *uuid.UUID {
+	if s == nil {
+		return nil
+	}
+	id := p.required(field, *s)
+	return &id
+}
+
+func optionalUUID(id *uuid.UUID) *string {
+	if id == nil {
+		return nil
+	}
+	s := id.String()
+	return &s
+}
@@ -0,0 +1,130 @@
+package kafka
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/red-velvet-workspace/banco-digital/internal/audit"
+	"github.com/red-velvet-workspace/banco-digital/internal/infrastructure/kafka/eventspb"
+	"google.golang.org/protobuf/proto"
+)
+
+func testEnvelope(eventType string) Envelope {
+	return Envelope{
+		EventID:       uuid.New(),
+		EventType:     eventType,
+		SchemaVersion: SchemaVersion,
+		OccurredAt:    time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.UTC),
+		Producer:      "teste",
+		CorrelationID: "req-1",
+		CausationID:   "req-1",
+		Actor:         audit.Actor{ID: "usuario-1", Role: "customer", IP: "203.0.113.7", RequestID: "req-1", AuthMethod: "jwt"},
+	}
+}
+
+func ptrUUID() *uuid.UUID {
+	id := uuid.New()
+	return &id
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 500000000, time.UTC)
+	expires := at.Add(7 * 24 * time.Hour)
+	key := "cliente@example.com"
+
+	tests := []struct {
+		name      string
+		eventType string
+		payload   interface{}
+		// out recebe o payload decodificado
+		out func() interface{}
+	}{
+		{"conta", "account.created", AccountPayload{
+			AccountID: uuid.New(), Type: "CHECKING", Number: "12345-6", OwnerID: uuid.NewString(),
+			Status: "ACTIVE", Balance: 1234.56, CreatedAt: at, UpdatedAt: at.Add(time.Minute),
+		}, func() interface{} { return &AccountPayload{} }},
+		{"chave pix", "pix_key.created", PIXKeyPayload{
+			PIXKeyID: uuid.New(), AccountID: uuid.New(), KeyType: "EMAIL", Key: key, CreatedAt: at, UpdatedAt: at,
+		}, func() interface{} { return &PIXKeyPayload{} }},
+		{"cartão", "credit_card.limit_updated", CreditCardPayload{
+			CreditCardID: uuid.New(), AccountID: uuid.New(), Number: "4532-abc", ExpirationDate: at.AddDate(5, 0, 0),
+			CreditLimit: 5000, AvailableLimit: 3210.5, CreditBalance: 1789.5, StatementDate: 3, DueDate: 10,
+			CreatedAt: at, UpdatedAt: at,
+		}, func() interface{} { return &CreditCardPayload{} }},
+		{"transação com opcionais", "transaction.authorized", TransactionPayload{
+			TransactionID: uuid.New(), AccountID: uuid.New(), Type: "CARD_PURCHASE", Amount: 99.9,
+			Description: "compra", DestinationKey: &key, CreditCardID: ptrUUID(), VirtualCardID: ptrUUID(),
+			AuthorizationID: ptrUUID(), RelatedID: ptrUUID(), Installments: 3, InterestRate: 0.0299,
+			MerchantName: "Loja", MCC: "5411", Category: "GROCERY", Country: "BR", Channel: "CHIP",
+			CreatedAt: at, ExpiresAt: &expires,
+		}, func() interface{} { return &TransactionPayload{} }},
+		{"transação sem opcionais", "transaction.created", TransactionPayload{
+			TransactionID: uuid.New(), AccountID: uuid.New(), Type: "DEPOSIT", Amount: 10, CreatedAt: at,
+		}, func() interface{} { return &TransactionPayload{} }},
+	}
+
+	for _, tt := range tests {
+		decoded := make(map[string]interface{})
+		for _, codec := range []Codec{JSONCodec, ProtobufCodec} {
+			envelope := testEnvelope(tt.eventType)
+			data, err := codec.Marshal(envelope, tt.payload)
+			if err != nil {
+				t.Fatalf("%s, %s: Marshal: %v", tt.name, codec.ContentType(), err)
+			}
+			got, err := codec.Unmarshal(data)
+			if err != nil {
+				t.Fatalf("%s, %s: Unmarshal: %v", tt.name, codec.ContentType(), err)
+			}
+			header := got
+			header.Payload = nil
+			if !reflect.DeepEqual(header, envelope) {
+				t.Errorf("%s, %s: envelope\n  %+v\nesperado\n  %+v", tt.name, codec.ContentType(), header, envelope)
+			}
+
+			out := tt.out()
+			if err := codec.UnmarshalPayload(got, out); err != nil {
+				t.Fatalf("%s, %s: UnmarshalPayload: %v", tt.name, codec.ContentType(), err)
+			}
+			payload := reflect.ValueOf(out).Elem().Interface()
+			if !reflect.DeepEqual(payload, tt.payload) {
+				t.Errorf("%s, %s: payload\n  %+v\nesperado\n  %+v", tt.name, codec.ContentType(), payload, tt.payload)
+			}
+			decoded[codec.ContentType()] = payload
+		}
+		if !reflect.DeepEqual(decoded[ContentTypeJSON], decoded[ContentTypeProtobuf]) {
+			t.Errorf("%s: JSON e Protobuf decodificam diferente", tt.name)
+		}
+	}
+}
+
+func TestProtobufRejectsMalformedIDs(t *testing.T) {
+	bad := "não-é-uuid"
+	valid := uuid.NewString()
+
+	tests := []struct {
+		name    string
+		message proto.Message
+		out     interface{}
+		field   string
+	}{
+		{"conta", &eventspb.AccountEvent{AccountId: bad}, &AccountPayload{}, "account_id"},
+		{"chave pix", &eventspb.PIXKeyEvent{PixKeyId: valid, AccountId: bad}, &PIXKeyPayload{}, "account_id"},
+		{"cartão", &eventspb.CreditCardEvent{CreditCardId: bad, AccountId: valid}, &CreditCardPayload{}, "credit_card_id"},
+		{"transação sem conta", &eventspb.TransactionEvent{TransactionId: valid}, &TransactionPayload{}, "account_id"},
+		{"cartão da transação", &eventspb.TransactionEvent{TransactionId: valid, AccountId: valid, CreditCardId: &bad}, &TransactionPayload{}, "credit_card_id"},
+		{"compra de origem", &eventspb.TransactionEvent{TransactionId: valid, AccountId: valid, RelatedId: &bad}, &TransactionPayload{}, "related_id"},
+	}
+	for _, tt := range tests {
+		data, err := proto.Marshal(tt.message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ProtobufCodec.UnmarshalPayload(Envelope{Payload: data}, tt.out)
+		if err == nil || !strings.Contains(err.Error(), tt.field) {
+			t.Errorf("%s: erro %v, esperado erro em %s", tt.name, err, tt.field)
+		}
+	}
+}
@@ -2,116 +2,240 @@ package kafka
 
 import (
     "context"
-    "encoding/json"
+    "errors"
+    "fmt"
     "log"
+    "strings"
+    "time"
 
-    	"github.com/Shopify/sarama"
+	"github.com/google/uuid"
+	"github.com/red-velvet-workspace/banco-digital/internal/audit"
 	"github.com/red-velvet-workspace/banco-digital/internal/domain/models"
-	"github.com/red-velvet-workspace/banco-digital/internal/infrastructure/database"
+	"github.com/red-velvet-workspace/banco-digital/internal/repositories"
 	"gorm.io/gorm"
 )
 
-type Consumer struct {
-    consumer sarama.Consumer
+var errAuthorizationNotPending = errors.New("pré-autorização não está pendente")
+
+// transactionRejection é devolvida dentro da transação do banco quando a
+// mensagem não pode ser aplicada por regra de negócio, com o motivo.
+type transactionRejection string
+
+func (r transactionRejection) Error() string {
+	return string(r)
 }
 
-func NewConsumer(brokers []string) (*Consumer, error) {
-    config := sarama.NewConfig()
-    config.Consumer.Return.Errors = true
+func rejected(reason string) error {
+	return transactionRejection(reason)
+}
 
-    consumer, err := sarama.NewConsumer(brokers, config)
-    if err != nil {
-        return nil, err
-    }
+// Consumer aplica no banco as mensagens dos tópicos, recebidas de qualquer
+// Subscriber. As que falham são reencaminhadas pelo Publisher.
+type Consumer struct {
+    projector  *Projector
+    subscriber Subscriber
+    publisher  Publisher
+    groupID    string
+    retry      RetryConfig
+}
 
+// NewConsumer cria o consumidor que aplica as mensagens em db. publisher
+// publica as mensagens que falharam nos tópicos de retentativa e de
+// mensagens mortas.
+func NewConsumer(db *gorm.DB, subscriber Subscriber, publisher Publisher, cfg ConsumerConfig) (*Consumer, error) {
+    projector := NewProjector(db)
+    projector.legacyCutover = cfg.LegacyCutover
     return &Consumer{
-        consumer: consumer,
+        projector:  projector,
+        subscriber: subscriber,
+        publisher:  publisher,
+        groupID:    cfg.GroupID,
+        retry:      cfg.Retry,
     }, nil
 }
 
-func (c *Consumer) consumeTopic(ctx context.Context, topic string, handler func([]byte) error) error {
-    partitionConsumer, err := c.consumer.ConsumePartition(topic, 0, sarama.OffsetNewest)
+// consumeTopic entra no grupo de consumo do tópico e processa as partições
+// atribuídas a esta réplica, do tópico e do seu tópico de retentativa, até o
+// contexto ser cancelado ou o consumidor ser fechado. Mensagens que falham
+// voltam pelo tópico de retentativa e, esgotadas as tentativas, vão para a
+// DLQ; por isso uma mensagem reprocessada pode ser aplicada depois de outras
+// mais novas da mesma chave.
+func (c *Consumer) consumeTopic(ctx context.Context, topic string, handler func(*Message) error) error {
+    retryTopic := RetryTopic(topic)
+    return c.subscriber.Subscribe(ctx, c.groupID+"."+topic, []string{topic, retryTopic}, func(ctx context.Context, msg *Message) error {
+        if msg.Topic == retryTopic && !waitRetry(ctx, msg) {
+            return ctx.Err()
+        }
+        if err := handler(msg); err != nil {
+            log.Printf("Error processing message from topic %s (partition %d, offset %d): %v",
+                msg.Topic, msg.Partition, msg.Offset, err)
+            if !c.forwardFailure(ctx, topic, msg, err) {
+                return ctx.Err()
+            }
+        }
+        return nil
+    })
+}
+
+func (c *Consumer) ConsumeAccounts(ctx context.Context) error {
+    return c.consumeTopic(ctx, TopicAccounts, c.projector.applyAccount)
+}
+
+func (p *Projector) applyAccount(m *Message) error {
+    msg, err := decodeAccountMessage(m)
     if err != nil {
-        log.Printf("Failed to start consumer for topic %s: %v", topic, err)
         return err
     }
+    if p.skipLegacy(m, msg.EventID) {
+        return nil
+    }
 
-    defer func() {
-        if err := partitionConsumer.Close(); err != nil {
-            log.Printf("Failed to close partition consumer: %v", err)
+    return p.db.Transaction(func(tx *gorm.DB) error {
+        if fresh, err := claimEvent(tx, TopicAccounts, msg.EventID); err != nil || !fresh {
+            return err
         }
-    }()
 
-    for {
-        select {
-        case msg := <-partitionConsumer.Messages():
-            if err := handler(msg.Value); err != nil {
-                log.Printf("Error processing message from topic %s: %v", topic, err)
+        if msg.Operation == "CREATE" {
+            if err := tx.Create(&msg.Account).Error; err != nil {
+                return err
             }
-        case err := <-partitionConsumer.Errors():
-            log.Printf("Error consuming message from topic %s: %v", topic, err)
+            return audit.Record(tx, msg.Actor, "account.create", "account", msg.Account.ID.String(), nil, msg.Account)
+        }
+        var before models.Account
+        if err := tx.First(&before, "id = ?", msg.Account.ID).Error; err != nil {
             return err
-        case <-ctx.Done():
-            return ctx.Err()
         }
-    }
+        if err := tx.Save(&msg.Account).Error; err != nil {
+            return err
+        }
+        return audit.Record(tx, msg.Actor, "account.update", "account", msg.Account.ID.String(), before, msg.Account)
+    })
 }
 
-func (c *Consumer) ConsumeAccounts() error {
-    return c.consumeTopic(context.Background(), TopicAccounts, func(data []byte) error {
-        var msg AccountMessage
-        if err := json.Unmarshal(data, &msg); err != nil {
+func (c *Consumer) ConsumePIXKeys(ctx context.Context) error {
+    return c.consumeTopic(ctx, TopicPIX, c.projector.applyPIXKey)
+}
+
+func (p *Projector) applyPIXKey(m *Message) error {
+    msg, err := decodePIXKeyMessage(m)
+    if err != nil {
+        return err
+    }
+    if p.skipLegacy(m, msg.EventID) {
+        return nil
+    }
+
+    return p.db.Transaction(func(tx *gorm.DB) error {
+        if fresh, err := claimEvent(tx, TopicPIX, msg.EventID); err != nil || !fresh {
             return err
         }
 
         if msg.Operation == "CREATE" {
-            return database.DB.Create(&msg.Account).Error
+            if err := tx.Create(&msg.PIXKey).Error; err != nil {
+                return err
+            }
+            return audit.Record(tx, msg.Actor, "pix_key.create", "pix_key", msg.PIXKey.ID.String(), nil, msg.PIXKey)
+        }
+        if err := tx.Delete(&msg.PIXKey).Error; err != nil {
+            return err
         }
-        return database.DB.Save(&msg.Account).Error
+        return audit.Record(tx, msg.Actor, "pix_key.delete", "pix_key", msg.PIXKey.ID.String(), msg.PIXKey, nil)
     })
 }
 
-func (c *Consumer) ConsumePIXKeys() error {
-    return c.consumeTopic(context.Background(), TopicPIX, func(data []byte) error {
-        var msg PIXKeyMessage
-        if err := json.Unmarshal(data, &msg); err != nil {
+func (c *Consumer) ConsumeCreditCards(ctx context.Context) error {
+    return c.consumeTopic(ctx, TopicCreditCards, c.projector.applyCreditCard)
+}
+
+func (p *Projector) applyCreditCard(m *Message) error {
+    msg, err := decodeCreditCardMessage(m)
+    if err != nil {
+        return err
+    }
+    if p.skipLegacy(m, msg.EventID) {
+        return nil
+    }
+
+    return p.db.Transaction(func(tx *gorm.DB) error {
+        if fresh, err := claimEvent(tx, TopicCreditCards, msg.EventID); err != nil || !fresh {
             return err
         }
 
+        cardID := msg.CreditCard.ID.String()
         if msg.Operation == "CREATE" {
-            return database.DB.Create(&msg.PIXKey).Error
+            if err := tx.Create(&msg.CreditCard).Error; err != nil {
+                return err
+            }
+            return audit.Record(tx, msg.Actor, "credit_card.create", "credit_card", cardID, nil, msg.CreditCard)
         }
-        return database.DB.Delete(&msg.PIXKey).Error
-    })
-}
 
-func (c *Consumer) ConsumeCreditCards() error {
-    return c.consumeTopic(context.Background(), TopicCreditCards, func(data []byte) error {
-        var msg CreditCardMessage
-        if err := json.Unmarshal(data, &msg); err != nil {
+        var before models.CreditCard
+        if err := tx.First(&before, "id = ?", msg.CreditCard.ID).Error; err != nil {
+            return err
+        }
+        action := "credit_card.update"
+        // Alteração de limite: o disponível acompanha a diferença sem
+        // sobrescrever compras processadas desde a publicação
+        if msg.Operation == "UPDATE_LIMIT" {
+            action = "credit_card.limit_update"
+            if err := tx.Model(&models.CreditCard{}).Where("id = ?", msg.CreditCard.ID).
+                Updates(map[string]interface{}{
+                    "available_limit": gorm.Expr("available_limit + ? - credit_limit", msg.CreditCard.CreditLimit),
+                    "credit_limit":    msg.CreditCard.CreditLimit,
+                    "updated_at":      time.Now(),
+                }).Error; err != nil {
+                return err
+            }
+        } else if err := tx.Save(&msg.CreditCard).Error; err != nil {
             return err
         }
 
-        if msg.Operation == "CREATE" {
-            return database.DB.Create(&msg.CreditCard).Error
+        var after models.CreditCard
+        if err := tx.First(&after, "id = ?", msg.CreditCard.ID).Error; err != nil {
+            return err
         }
-        return database.DB.Save(&msg.CreditCard).Error
+        return audit.Record(tx, msg.Actor, action, "credit_card", cardID, before, after)
     })
 }
 
-func (c *Consumer) ConsumeTransactions() error {
-	return c.consumeTopic(context.Background(), TopicTransactions, func(data []byte) error {
-		var msg TransactionMessage
-		if err := json.Unmarshal(data, &msg); err != nil {
-			return err
+func (c *Consumer) ConsumeTransactions(ctx context.Context) error {
+	return c.consumeTopic(ctx, TopicTransactions, c.projector.applyTransaction)
+}
+
+func (p *Projector) applyTransaction(m *Message) error {
+	msg, err := decodeTransactionMessage(m)
+	if err != nil {
+		return err
+	}
+	if p.skipLegacy(m, msg.EventID) {
+		return nil
+	}
+
+	// Pré-autorizações apenas reservam ou liberam limite
+	switch msg.Operation {
+	case "AUTHORIZE":
+		return p.authorizeCardPurchase(msg.EventID, msg.Transaction, msg.ExpiresAt, msg.Actor)
+	case "VOID", "EXPIRE":
+		if msg.Transaction.AuthorizationID == nil {
+			return fmt.Errorf("mensagem %s sem autorização", msg.Operation)
 		}
+		status := models.AuthorizationVoided
+		if msg.Operation == "EXPIRE" {
+			status = models.AuthorizationExpired
+		}
+		return p.releaseAuthorization(msg.EventID, *msg.Transaction.AuthorizationID, status, msg.Actor)
+	}
 
-		tx := database.DB.Begin()
-		if tx.Error != nil {
-			return tx.Error
+	transaction := msg.Transaction
+	err = p.db.Transaction(func(tx *gorm.DB) error {
+		if fresh, err := claimEvent(tx, TopicTransactions, msg.EventID); err != nil || !fresh {
+			return err
 		}
 
-		transaction := msg.Transaction
+		before, err := balanceSnapshot(tx, transaction)
+		if err != nil {
+			return err
+		}
 
 		// Processar baseada no tipo
 		switch transaction.Type {
@@ -119,7 +243,6 @@ func (c *Consumer) ConsumeTransactions() error {
 			// Adicionar ao saldo
 			if err := tx.Model(&models.Account{}).Where("id = ?", transaction.AccountID).
 				UpdateColumn("balance", gorm.Expr("balance + ?", transaction.Amount)).Error; err != nil {
-				tx.Rollback()
 				return err
 			}
 
@@ -127,43 +250,267 @@ func (c *Consumer) ConsumeTransactions() error {
 			// Subtrair do saldo
 			if err := tx.Model(&models.Account{}).Where("id = ?", transaction.AccountID).
 				UpdateColumn("balance", gorm.Expr("balance - ?", transaction.Amount)).Error; err != nil {
-				tx.Rollback()
 				return err
 			}
 
 		case models.CardPurchase:
-			// Subtrair do limite disponível
+			// Captura de pré-autorização: libera o valor reservado antes de
+			// debitar o valor capturado
+			if transaction.AuthorizationID != nil {
+				if err := captureAuthorization(tx, transaction); err != nil {
+					if err == errAuthorizationNotPending {
+						return rejected("Pré-autorização não está mais pendente")
+					}
+					return err
+				}
+			}
+			// Subtrair do limite disponível; a condição do UPDATE impede que
+			// compras concorrentes estourem o limite
 			if transaction.CreditCardID != nil {
-				if err := tx.Model(&models.CreditCard{}).Where("id = ?", *transaction.CreditCardID).
-					UpdateColumn("available_limit", gorm.Expr("available_limit - ?", transaction.Amount)).Error; err != nil {
-					tx.Rollback()
+				result := tx.Model(&models.CreditCard{}).
+					Where("id = ? AND available_limit >= ?", *transaction.CreditCardID, transaction.Amount).
+					UpdateColumn("available_limit", gorm.Expr("available_limit - ?", transaction.Amount))
+				if result.Error != nil {
+					return result.Error
+				}
+				if result.RowsAffected == 0 {
+					return rejected("Limite insuficiente")
+				}
+				// Registrar o gasto no cartão virtual, respeitando uso único e limite
+				if transaction.VirtualCardID != nil {
+					if err := chargeVirtualCard(tx, *transaction.VirtualCardID, transaction.Amount); err != nil {
+						return err
+					}
+				}
+				// Lançar as parcelas nas faturas
+				if err := createInstallments(tx, transaction); err != nil {
 					return err
 				}
 			}
 
 		case models.CardPayment:
-			// Restaurar limite disponível (e talvez debitar da conta se for pagamento com saldo, mas assumindo pagamento externo ou lógica separada)
-			// Se o pagamento for feito com saldo da conta, deveria ser uma transação composta.
-			// Por simplicidade, assumimos que CardPayment aqui restaura o limite.
-			if transaction.CreditCardID != nil {
+			// Pagamento de fatura com saldo da conta: o débito, a liberação do
+			// limite e a baixa nas faturas acontecem na mesma transação
+			if transaction.CreditCardID == nil {
+				return fmt.Errorf("pagamento %s sem cartão", transaction.ID)
+			}
+			result := tx.Model(&models.Account{}).
+				Where("id = ? AND balance >= ?", transaction.AccountID, transaction.Amount).
+				UpdateColumn("balance", gorm.Expr("balance - ?", transaction.Amount))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return rejected("Saldo insuficiente para pagamento da fatura")
+			}
+			if err := tx.Model(&models.CreditCard{}).Where("id = ?", *transaction.CreditCardID).
+				UpdateColumn("available_limit", gorm.Expr("available_limit + ?", transaction.Amount)).Error; err != nil {
+				return err
+			}
+			// O que exceder as faturas fica como saldo credor no cartão
+			overpayment, err := repositories.NewInvoiceRepository(tx).
+				ApplyPayment(context.Background(), *transaction.CreditCardID, transaction.RelatedID, transaction.Amount)
+			if err != nil {
+				return err
+			}
+			if overpayment > 0 {
 				if err := tx.Model(&models.CreditCard{}).Where("id = ?", *transaction.CreditCardID).
-					UpdateColumn("available_limit", gorm.Expr("available_limit + ?", transaction.Amount)).Error; err != nil {
-					tx.Rollback()
+					UpdateColumn("credit_balance", gorm.Expr("credit_balance + ?", overpayment)).Error; err != nil {
 					return err
 				}
 			}
+
+		case models.CardPrepayment:
+			// Antecipação: debita o valor com desconto da conta e libera o limite
+			// pelo valor em aberto das parcelas antecipadas
+			if transaction.CreditCardID == nil || transaction.RelatedID == nil {
+				return fmt.Errorf("antecipação %s sem cartão ou compra de origem", transaction.ID)
+			}
+			result := tx.Model(&models.Account{}).
+				Where("id = ? AND balance >= ?", transaction.AccountID, transaction.Amount).
+				UpdateColumn("balance", gorm.Expr("balance - ?", transaction.Amount))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return rejected("Saldo insuficiente para antecipação")
+			}
+			outstanding, err := repositories.NewInvoiceRepository(tx).Prepay(context.Background(), *transaction.RelatedID)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&models.CreditCard{}).Where("id = ?", *transaction.CreditCardID).
+				UpdateColumn("available_limit", gorm.Expr("available_limit + ?", outstanding)).Error; err != nil {
+				return err
+			}
 		}
 
 		// Persistir transação
 		if err := tx.Create(&transaction).Error; err != nil {
-			tx.Rollback()
 			return err
 		}
 
-		return tx.Commit().Error
+		// Auditar com os saldos antes e depois da transação
+		after, err := balanceSnapshot(tx, transaction)
+		if err != nil {
+			return err
+		}
+		after["transaction"] = transaction
+		action := "transaction." + strings.ToLower(string(transaction.Type))
+		return audit.Record(tx, msg.Actor, action, "transaction", transaction.ID.String(), before, after)
 	})
+
+	// Recusas por regra de negócio desfazem a transação e só então avisam o
+	// cliente
+	var rejection transactionRejection
+	if errors.As(err, &rejection) {
+		return p.rejectTransaction(msg.EventID, transaction, string(rejection))
+	}
+	return err
+}
+
+// balanceSnapshot captura a conta e o cartão afetados pela transação para o
+// log de auditoria.
+func balanceSnapshot(tx *gorm.DB, transaction models.Transaction) (map[string]interface{}, error) {
+	snapshot := map[string]interface{}{}
+
+	var account models.Account
+	if err := tx.Limit(1).Find(&account, "id = ?", transaction.AccountID).Error; err != nil {
+		return nil, err
+	}
+	snapshot["account"] = account
+
+	if transaction.CreditCardID != nil {
+		var card models.CreditCard
+		if err := tx.Limit(1).Find(&card, "id = ?", *transaction.CreditCardID).Error; err != nil {
+			return nil, err
+		}
+		snapshot["credit_card"] = card
+	}
+	return snapshot, nil
+}
+
+// chargeVirtualCard soma a compra ao gasto do cartão virtual. A condição do
+// UPDATE garante que um cartão de uso único ou com limite esgotado não seja
+// usado duas vezes mesmo com compras concorrentes; nesse caso a compra é
+// rejeitada.
+func chargeVirtualCard(tx *gorm.DB, virtualCardID uuid.UUID, amount float64) error {
+	result := tx.Model(&models.VirtualCard{}).
+		Where("id = ? AND status = ? AND expiration_date > ?", virtualCardID, models.VirtualCardActive, time.Now()).
+		Where("spending_limit IS NULL OR spent_amount + ? <= spending_limit", amount).
+		Updates(map[string]interface{}{
+			"spent_amount": gorm.Expr("spent_amount + ?", amount),
+			"status":       gorm.Expr("CASE WHEN single_use THEN ? ELSE status END", models.VirtualCardUsed),
+			"updated_at":   time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return rejected("Cartão virtual indisponível")
+	}
+	return nil
+}
+
+// createInstallments divide a compra em parcelas e lança cada uma na fatura
+// do mês correspondente, a partir da fatura aberta na data da compra.
+func createInstallments(tx *gorm.DB, transaction models.Transaction) error {
+	var card models.CreditCard
+	if err := tx.First(&card, "id = ?", *transaction.CreditCardID).Error; err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	repo := repositories.NewInvoiceRepository(tx)
+	amounts := models.SplitInstallments(transaction.Amount, transaction.Installments)
+	installments := make([]models.Installment, 0, len(amounts))
+	for i, amount := range amounts {
+		month, closing, due := card.InvoicePeriod(transaction.CreatedAt, i)
+		invoice, err := repo.FindOrCreate(ctx, card.ID, month, closing, due)
+		if err != nil {
+			return err
+		}
+		if err := repo.AddAmount(ctx, invoice.ID, amount); err != nil {
+			return err
+		}
+		installments = append(installments, models.Installment{
+			ID:                uuid.New(),
+			TransactionID:     transaction.ID,
+			CreditCardID:      card.ID,
+			InvoiceID:         invoice.ID,
+			Number:            i + 1,
+			TotalInstallments: len(amounts),
+			Amount:            amount,
+			DueDate:           due,
+			Status:            models.InstallmentPending,
+			CreatedAt:         time.Now(),
+			UpdatedAt:         time.Now(),
+		})
+	}
+	if err := repo.CreateInstallments(ctx, installments); err != nil {
+		return err
+	}
+	// Saldo credor de pagamentos anteriores abate as novas parcelas
+	return repo.ApplyCredit(ctx, card.ID)
+}
+
+// rejectTransaction descarta uma transação que não pode ser aplicada por
+// regra de negócio e avisa o cliente. A mensagem é confirmada normalmente,
+// pois reprocessá-la daria o mesmo resultado; o evento fica registrado junto
+// com o aviso para uma reentrega não avisar de novo.
+func (p *Projector) rejectTransaction(eventID uuid.UUID, transaction models.Transaction, reason string) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		if fresh, err := claimEvent(tx, TopicTransactions, eventID); err != nil || !fresh {
+			return err
+		}
+
+		log.Printf("Transaction %s rejected: %s", transaction.ID, reason)
+		notification := models.Notification{
+			ID:        uuid.New(),
+			AccountID: transaction.AccountID,
+			Type:      "TRANSACTION_REJECTED",
+			Message:   fmt.Sprintf("%s: R$ %.2f", reason, transaction.Amount),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		return tx.Create(&notification).Error
+	})
+}
+
+// Run consome todos os tópicos até ctx ser cancelado. Cada consumidor
+// termina a mensagem em andamento antes de parar; se um deles falhar, os
+// demais são cancelados e o erro é devolvido.
+func (c *Consumer) Run(ctx context.Context) error {
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+
+    consumers := []func(context.Context) error{
+        c.ConsumeAccounts,
+        c.ConsumePIXKeys,
+        c.ConsumeCreditCards,
+        c.ConsumeTransactions,
+    }
+    errs := make(chan error, len(consumers))
+    for _, consume := range consumers {
+        go func(consume func(context.Context) error) {
+            errs <- consume(ctx)
+        }(consume)
+    }
+
+    var first error
+    for range consumers {
+        err := <-errs
+        if first == nil && ctx.Err() == nil {
+            first = err
+            if first == nil {
+                first = errors.New("consumidor terminou inesperadamente")
+            }
+            cancel()
+        }
+    }
+    return first
 }
 
 func (c *Consumer) Close() error {
-    return c.consumer.Close()
+    return c.subscriber.Close()
 }
@@ -0,0 +1,187 @@
+package kafka
+
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Shopify/sarama"
+)
+
+// DeadLetter é uma mensagem parada na DLQ de um tópico.
+type DeadLetter struct {
+	Partition int32             `json:"partition"`
+	Offset    int64             `json:"offset"`
+	Timestamp time.Time         `json:"timestamp"`
+	Key       string            `json:"key"`
+	Headers   map[string]string `json:"headers"`
+	// Value é o corpo da mensagem: o JSON como texto ou, nos demais
+	// content-types, em base64
+	Value string `json:"value"`
+	raw   []byte
+}
+
+// DeadLetterQueue lê a DLQ de um tópico e reenvia as mensagens para ele.
+// Ler não confirma nada: as mensagens continuam na DLQ até a retenção do
+// tópico. Reenviar a mesma mensagem duas vezes é seguro, pois o EventID é
+// mantido e o consumidor ignora a segunda entrega.
+type DeadLetterQueue struct {
+	topic    string
+	client   sarama.Client
+	consumer sarama.Consumer
+	producer *Producer
+}
+
+// OpenDeadLetterQueue conecta à DLQ de topic.
+func OpenDeadLetterQueue(cfg ClientConfig, topic string) (*DeadLetterQueue, error) {
+	config, err := cfg.saramaConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := sarama.NewClient(cfg.Brokers, config)
+	if err != nil {
+		return nil, err
+	}
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	producer, err := NewProducer(cfg, Codecs{})
+	if err != nil {
+		consumer.Close()
+		client.Close()
+		return nil, err
+	}
+
+	return &DeadLetterQueue{
+		topic:    topic,
+		client:   client,
+		consumer: consumer,
+		producer: producer,
+	}, nil
+}
+
+// List devolve até limit mensagens de cada partição da DLQ, das mais antigas
+// para as mais novas. limit <= 0 devolve todas.
+func (q *DeadLetterQueue) List(limit int) ([]DeadLetter, error) {
+	dlq := DeadLetterTopic(q.topic)
+	partitions, err := q.client.Partitions(dlq)
+	if err != nil {
+		return nil, err
+	}
+
+	var letters []DeadLetter
+	for _, partition := range partitions {
+		oldest, err := q.client.GetOffset(dlq, partition, sarama.OffsetOldest)
+		if err != nil {
+			return nil, err
+		}
+		newest, err := q.client.GetOffset(dlq, partition, sarama.OffsetNewest)
+		if err != nil {
+			return nil, err
+		}
+		count := newest - oldest
+		if limit > 0 && count > int64(limit) {
+			count = int64(limit)
+		}
+		read, err := q.read(partition, oldest, count)
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, read...)
+	}
+	return letters, nil
+}
+
+// Get devolve a mensagem da DLQ na partição e offset informados.
+func (q *DeadLetterQueue) Get(partition int32, offset int64) (*DeadLetter, error) {
+	read, err := q.read(partition, offset, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(read) == 0 || read[0].Offset != offset {
+		return nil, fmt.Errorf("mensagem %d/%d não encontrada em %s", partition, offset, DeadLetterTopic(q.topic))
+	}
+	return &read[0], nil
+}
+
+// Redrive publica a mensagem de novo no tópico original, com a mesma chave e
+// sem a contagem de tentativas, para ela voltar a ser processada do zero.
+func (q *DeadLetterQueue) Redrive(letter DeadLetter) error {
+	topic := letter.Headers[HeaderOriginalTopic]
+	if topic == "" {
+		topic = q.topic
+	}
+	headers := map[string]string{
+		HeaderRedrivenFrom: fmt.Sprintf("%s/%d/%d", DeadLetterTopic(q.topic), letter.Partition, letter.Offset),
+	}
+	if contentType := letter.Headers[HeaderContentType]; contentType != "" {
+		headers[HeaderContentType] = contentType
+	}
+
+	var key []byte
+	if letter.Key != "" {
+		key = []byte(letter.Key)
+	}
+	value := letter.raw
+	if value == nil {
+		value = []byte(letter.Value)
+	}
+	return q.producer.Publish(topic, key, value, headers)
+}
+
+func (q *DeadLetterQueue) Close() error {
+	q.producer.Close()
+	q.consumer.Close()
+	return q.client.Close()
+}
+
+// read lê até count mensagens da partição a partir de offset.
+func (q *DeadLetterQueue) read(partition int32, offset int64, count int64) ([]DeadLetter, error) {
+	if count <= 0 {
+		return nil, nil
+	}
+	pc, err := q.consumer.ConsumePartition(DeadLetterTopic(q.topic), partition, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer pc.Close()
+
+	letters := make([]DeadLetter, 0, count)
+	for int64(len(letters)) < count {
+		select {
+		case msg := <-pc.Messages():
+			letters = append(letters, deadLetterFrom(msg))
+		case err := <-pc.Errors():
+			return nil, err
+		case <-time.After(10 * time.Second):
+			return letters, nil
+		}
+	}
+	return letters, nil
+}
+
+func deadLetterFrom(msg *sarama.ConsumerMessage) DeadLetter {
+	headers := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers[string(h.Key)] = string(h.Value)
+	}
+	return DeadLetter{
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Timestamp: msg.Timestamp,
+		Key:       string(msg.Key),
+		Headers:   headers,
+		Value:     printableValue(headers[HeaderContentType], msg.Value),
+		raw:       msg.Value,
+	}
+}
+
+func printableValue(contentType string, value []byte) string {
+	if (contentType == "" || contentType == ContentTypeJSON) && utf8.Valid(value) {
+		return string(value)
+	}
+	return base64.StdEncoding.EncodeToString(value)
+}
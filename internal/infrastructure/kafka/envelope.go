@@ -0,0 +1,136 @@
+package kafka
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/red-velvet-workspace/banco-digital/internal/audit"
+)
+
+// Versões do esquema das mensagens. A versão 1 é a mensagem sem envelope,
+// com os modelos do banco; a 2 é o Envelope com os payloads de events.go.
+// Os consumidores aceitam as duas.
+const (
+	schemaV1      = 1
+	schemaV2      = 2
+	SchemaVersion = schemaV2
+)
+
+// Envelope carrega os metadados comuns a todos os eventos publicados.
+// CorrelationID liga os eventos de uma mesma requisição; CausationID é o que
+// disparou o evento (a requisição, para eventos publicados pela API).
+// Payload é o payload já serializado pelo Codec da mensagem.
+type Envelope struct {
+	EventID       uuid.UUID   `json:"event_id"`
+	EventType     string      `json:"event_type"`
+	SchemaVersion int         `json:"schema_version"`
+	OccurredAt    time.Time   `json:"occurred_at"`
+	Producer      string      `json:"producer"`
+	CorrelationID string      `json:"correlation_id,omitempty"`
+	CausationID   string      `json:"causation_id,omitempty"`
+	Actor         audit.Actor `json:"actor"`
+	Payload       []byte      `json:"-"`
+}
+
+// eventMessage é implementada pelas mensagens de messages.go.
+type eventMessage interface {
+	partitionKeyer
+	event() Event
+	// eventData devolve o tipo do evento, o ator e o payload publicado
+	eventData() (eventType string, actor audit.Actor, payload interface{})
+}
+
+func (e Event) event() Event {
+	return e
+}
+
+// newEnvelope monta o envelope da mensagem e devolve o payload a ser
+// serializado nele.
+func newEnvelope(message eventMessage, producer string) (Envelope, interface{}, error) {
+	event := message.event()
+	if event.EventID == uuid.Nil {
+		return Envelope{}, nil, fmt.Errorf("mensagem sem EventID")
+	}
+	eventType, actor, payload := message.eventData()
+	if eventType == "" {
+		return Envelope{}, nil, fmt.Errorf("operação sem tipo de evento")
+	}
+
+	occurredAt := event.OccurredAt
+	if occurredAt.IsZero() {
+		occurredAt = time.Now()
+	}
+	correlationID := firstNonEmpty(event.CorrelationID, actor.RequestID, event.EventID.String())
+	causationID := firstNonEmpty(event.CausationID, actor.RequestID)
+
+	return Envelope{
+		EventID:       event.EventID,
+		EventType:     eventType,
+		SchemaVersion: SchemaVersion,
+		OccurredAt:    occurredAt.UTC(),
+		Producer:      producer,
+		CorrelationID: correlationID,
+		CausationID:   causationID,
+		Actor:         actor,
+	}, payload, nil
+}
+
+// decodeEnvelope lê o envelope com o codec indicado no cabeçalho
+// content-type. Mensagens da versão 1 não têm envelope e voltam com
+// SchemaVersion 1 e o próprio corpo como payload.
+func decodeEnvelope(msg *Message) (Envelope, Codec, error) {
+	codec, err := codecFor(msg.Headers[HeaderContentType])
+	if err != nil {
+		return Envelope{}, nil, err
+	}
+	envelope, err := codec.Unmarshal(msg.Value)
+	if err != nil {
+		return Envelope{}, nil, err
+	}
+	switch envelope.SchemaVersion {
+	case schemaV1, schemaV2:
+		return envelope, codec, nil
+	default:
+		return Envelope{}, nil, fmt.Errorf("versão de esquema %d não suportada (evento %s)", envelope.SchemaVersion, envelope.EventID)
+	}
+}
+
+// event devolve os metadados do envelope na forma usada pelas mensagens.
+func (e Envelope) event() Event {
+	return Event{
+		EventID:       e.EventID,
+		OccurredAt:    e.OccurredAt,
+		CorrelationID: e.CorrelationID,
+		CausationID:   e.CausationID,
+	}
+}
+
+// operation traduz o tipo do evento na operação da mensagem.
+func (e Envelope) operation(eventTypes map[string]string) (string, error) {
+	for operation, eventType := range eventTypes {
+		if eventType == e.EventType {
+			return operation, nil
+		}
+	}
+	return "", fmt.Errorf("tipo de evento desconhecido: %s", e.EventType)
+}
+
+// producerName identifica a réplica que publicou o evento.
+func producerName() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "banco-digital"
+	}
+	return "banco-digital@" + host
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
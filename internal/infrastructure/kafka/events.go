@@ -0,0 +1,325 @@
+package kafka
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/red-velvet-workspace/banco-digital/internal/audit"
+	"github.com/red-velvet-workspace/banco-digital/internal/domain/models"
+)
+
+// Tipos de evento por operação de cada mensagem.
+var (
+	accountEventTypes = map[string]string{
+		"CREATE": "account.created",
+		"UPDATE": "account.updated",
+	}
+	pixKeyEventTypes = map[string]string{
+		"CREATE": "pix_key.created",
+		"DELETE": "pix_key.deleted",
+	}
+	creditCardEventTypes = map[string]string{
+		"CREATE":       "credit_card.created",
+		"UPDATE":       "credit_card.updated",
+		"UPDATE_LIMIT": "credit_card.limit_updated",
+	}
+	transactionEventTypes = map[string]string{
+		"CREATE":    "transaction.created",
+		"AUTHORIZE": "transaction.authorized",
+		"VOID":      "transaction.voided",
+		"EXPIRE":    "transaction.expired",
+	}
+)
+
+// Payloads da versão 2 do esquema. Eles descrevem o evento, não a tabela:
+// campos novos nos modelos só entram aqui quando fizerem parte do contrato.
+
+type AccountPayload struct {
+	AccountID uuid.UUID `json:"account_id"`
+	Type      string    `json:"type"`
+	Number    string    `json:"number"`
+	OwnerID   string    `json:"owner_id"`
+	Status    string    `json:"status"`
+	Balance   float64   `json:"balance"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type PIXKeyPayload struct {
+	PIXKeyID  uuid.UUID `json:"pix_key_id"`
+	AccountID uuid.UUID `json:"account_id"`
+	KeyType   string    `json:"key_type"`
+	Key       string    `json:"key"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CreditCardPayload não leva o CVV.
+type CreditCardPayload struct {
+	CreditCardID   uuid.UUID `json:"credit_card_id"`
+	AccountID      uuid.UUID `json:"account_id"`
+	Number         string    `json:"number"`
+	ExpirationDate time.Time `json:"expiration_date"`
+	CreditLimit    float64   `json:"credit_limit"`
+	AvailableLimit float64   `json:"available_limit"`
+	CreditBalance  float64   `json:"credit_balance"`
+	StatementDate  int       `json:"statement_date"`
+	DueDate        int       `json:"due_date"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type TransactionPayload struct {
+	TransactionID   uuid.UUID  `json:"transaction_id"`
+	AccountID       uuid.UUID  `json:"account_id"`
+	Type            string     `json:"type"`
+	Amount          float64    `json:"amount"`
+	Description     string     `json:"description"`
+	DestinationKey  *string    `json:"destination_key,omitempty"`
+	CreditCardID    *uuid.UUID `json:"credit_card_id,omitempty"`
+	VirtualCardID   *uuid.UUID `json:"virtual_card_id,omitempty"`
+	AuthorizationID *uuid.UUID `json:"authorization_id,omitempty"`
+	RelatedID       *uuid.UUID `json:"related_id,omitempty"`
+	Installments    int        `json:"installments,omitempty"`
+	InterestRate    float64    `json:"interest_rate,omitempty"`
+	MerchantName    string     `json:"merchant_name,omitempty"`
+	MCC             string     `json:"mcc,omitempty"`
+	Category        string     `json:"category,omitempty"`
+	Country         string     `json:"country,omitempty"`
+	Channel         string     `json:"channel,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	// ExpiresAt é a validade de uma pré-autorização (transaction.authorized)
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func (m AccountMessage) eventData() (string, audit.Actor, interface{}) {
+	a := m.Account
+	return accountEventTypes[m.Operation], m.Actor, AccountPayload{
+		AccountID: a.ID,
+		Type:      string(a.Type),
+		Number:    a.Number,
+		OwnerID:   a.OwnerID,
+		Status:    a.Status,
+		Balance:   a.Balance,
+		CreatedAt: a.CreatedAt,
+		UpdatedAt: a.UpdatedAt,
+	}
+}
+
+func (m PIXKeyMessage) eventData() (string, audit.Actor, interface{}) {
+	k := m.PIXKey
+	return pixKeyEventTypes[m.Operation], m.Actor, PIXKeyPayload{
+		PIXKeyID:  k.ID,
+		AccountID: k.AccountID,
+		KeyType:   k.KeyType,
+		Key:       k.Key,
+		CreatedAt: k.CreatedAt,
+		UpdatedAt: k.UpdatedAt,
+	}
+}
+
+func (m CreditCardMessage) eventData() (string, audit.Actor, interface{}) {
+	c := m.CreditCard
+	return creditCardEventTypes[m.Operation], m.Actor, CreditCardPayload{
+		CreditCardID:   c.ID,
+		AccountID:      c.AccountID,
+		Number:         c.Number,
+		ExpirationDate: c.ExpirationDate,
+		CreditLimit:    c.CreditLimit,
+		AvailableLimit: c.AvailableLimit,
+		CreditBalance:  c.CreditBalance,
+		StatementDate:  c.StatementDate,
+		DueDate:        c.DueDate,
+		CreatedAt:      c.CreatedAt,
+		UpdatedAt:      c.UpdatedAt,
+	}
+}
+
+func (m TransactionMessage) eventData() (string, audit.Actor, interface{}) {
+	t := m.Transaction
+	payload := TransactionPayload{
+		TransactionID:   t.ID,
+		AccountID:       t.AccountID,
+		Type:            string(t.Type),
+		Amount:          t.Amount,
+		Description:     t.Description,
+		DestinationKey:  t.DestinationKey,
+		CreditCardID:    t.CreditCardID,
+		VirtualCardID:   t.VirtualCardID,
+		AuthorizationID: t.AuthorizationID,
+		RelatedID:       t.RelatedID,
+		Installments:    t.Installments,
+		InterestRate:    t.InterestRate,
+		MerchantName:    t.MerchantName,
+		MCC:             t.MCC,
+		Category:        string(t.Category),
+		Country:         t.Country,
+		Channel:         string(t.Channel),
+		CreatedAt:       t.CreatedAt,
+	}
+	if !m.ExpiresAt.IsZero() {
+		expiresAt := m.ExpiresAt
+		payload.ExpiresAt = &expiresAt
+	}
+	return transactionEventTypes[m.Operation], m.Actor, payload
+}
+
+func decodeAccountMessage(m *Message) (AccountMessage, error) {
+	var msg AccountMessage
+	envelope, codec, err := decodeEnvelope(m)
+	if err != nil {
+		return msg, err
+	}
+	if envelope.SchemaVersion == schemaV1 {
+		err := json.Unmarshal(envelope.Payload, &msg)
+		return msg, err
+	}
+
+	var p AccountPayload
+	if err := codec.UnmarshalPayload(envelope, &p); err != nil {
+		return msg, err
+	}
+	operation, err := envelope.operation(accountEventTypes)
+	if err != nil {
+		return msg, err
+	}
+	return AccountMessage{
+		Event:     envelope.event(),
+		Operation: operation,
+		Actor:     envelope.Actor,
+		Account: models.Account{
+			ID:        p.AccountID,
+			Type:      models.AccountType(p.Type),
+			Number:    p.Number,
+			OwnerID:   p.OwnerID,
+			Status:    p.Status,
+			Balance:   p.Balance,
+			CreatedAt: p.CreatedAt,
+			UpdatedAt: p.UpdatedAt,
+		},
+	}, nil
+}
+
+func decodePIXKeyMessage(m *Message) (PIXKeyMessage, error) {
+	var msg PIXKeyMessage
+	envelope, codec, err := decodeEnvelope(m)
+	if err != nil {
+		return msg, err
+	}
+	if envelope.SchemaVersion == schemaV1 {
+		err := json.Unmarshal(envelope.Payload, &msg)
+		return msg, err
+	}
+
+	var p PIXKeyPayload
+	if err := codec.UnmarshalPayload(envelope, &p); err != nil {
+		return msg, err
+	}
+	operation, err := envelope.operation(pixKeyEventTypes)
+	if err != nil {
+		return msg, err
+	}
+	return PIXKeyMessage{
+		Event:     envelope.event(),
+		Operation: operation,
+		Actor:     envelope.Actor,
+		PIXKey: models.PIXKey{
+			ID:        p.PIXKeyID,
+			AccountID: p.AccountID,
+			KeyType:   p.KeyType,
+			Key:       p.Key,
+			CreatedAt: p.CreatedAt,
+			UpdatedAt: p.UpdatedAt,
+		},
+	}, nil
+}
+
+func decodeCreditCardMessage(m *Message) (CreditCardMessage, error) {
+	var msg CreditCardMessage
+	envelope, codec, err := decodeEnvelope(m)
+	if err != nil {
+		return msg, err
+	}
+	if envelope.SchemaVersion == schemaV1 {
+		err := json.Unmarshal(envelope.Payload, &msg)
+		return msg, err
+	}
+
+	var p CreditCardPayload
+	if err := codec.UnmarshalPayload(envelope, &p); err != nil {
+		return msg, err
+	}
+	operation, err := envelope.operation(creditCardEventTypes)
+	if err != nil {
+		return msg, err
+	}
+	return CreditCardMessage{
+		Event:     envelope.event(),
+		Operation: operation,
+		Actor:     envelope.Actor,
+		CreditCard: models.CreditCard{
+			ID:             p.CreditCardID,
+			AccountID:      p.AccountID,
+			Number:         p.Number,
+			ExpirationDate: p.ExpirationDate,
+			CreditLimit:    p.CreditLimit,
+			AvailableLimit: p.AvailableLimit,
+			CreditBalance:  p.CreditBalance,
+			StatementDate:  p.StatementDate,
+			DueDate:        p.DueDate,
+			CreatedAt:      p.CreatedAt,
+			UpdatedAt:      p.UpdatedAt,
+		},
+	}, nil
+}
+
+func decodeTransactionMessage(m *Message) (TransactionMessage, error) {
+	var msg TransactionMessage
+	envelope, codec, err := decodeEnvelope(m)
+	if err != nil {
+		return msg, err
+	}
+	if envelope.SchemaVersion == schemaV1 {
+		err := json.Unmarshal(envelope.Payload, &msg)
+		return msg, err
+	}
+
+	var p TransactionPayload
+	if err := codec.UnmarshalPayload(envelope, &p); err != nil {
+		return msg, err
+	}
+	operation, err := envelope.operation(transactionEventTypes)
+	if err != nil {
+		return msg, err
+	}
+	msg = TransactionMessage{
+		Event:     envelope.event(),
+		Operation: operation,
+		Actor:     envelope.Actor,
+		Transaction: models.Transaction{
+			ID:              p.TransactionID,
+			AccountID:       p.AccountID,
+			Type:            models.TransactionType(p.Type),
+			Amount:          p.Amount,
+			Description:     p.Description,
+			DestinationKey:  p.DestinationKey,
+			CreditCardID:    p.CreditCardID,
+			VirtualCardID:   p.VirtualCardID,
+			AuthorizationID: p.AuthorizationID,
+			RelatedID:       p.RelatedID,
+			Installments:    p.Installments,
+			InterestRate:    p.InterestRate,
+			MerchantName:    p.MerchantName,
+			MCC:             p.MCC,
+			Category:        models.MerchantCategory(p.Category),
+			Country:         p.Country,
+			Channel:         models.PurchaseChannel(p.Channel),
+			CreatedAt:       p.CreatedAt,
+		},
+	}
+	if p.ExpiresAt != nil {
+		msg.ExpiresAt = *p.ExpiresAt
+	}
+	return msg, nil
+}
@@ -0,0 +1,4 @@
+// Package eventspb contém as mensagens Protobuf dos eventos de domínio.
+package eventspb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative events.proto
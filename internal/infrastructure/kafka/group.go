@@ -0,0 +1,195 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// ConsumerConfig define o grupo de consumo das réplicas. Cada tópico usa o
+// grupo GroupID.<tópico>, então as partições de um tópico são divididas entre
+// as réplicas e os offsets confirmados sobrevivem a reinícios. InitialOffset
+// só vale para um grupo ainda sem offset confirmado.
+type ConsumerConfig struct {
+	GroupID       string
+	InitialOffset int64
+	Retry         RetryConfig
+	// LegacyCutover é o instante da atualização que introduziu o EventID.
+	// Mensagens sem EventID publicadas antes dele já foram aplicadas pela
+	// versão anterior e são ignoradas.
+	LegacyCutover time.Time
+}
+
+// LoadConsumerConfig lê KAFKA_CONSUMER_GROUP (padrão banco-digital),
+// KAFKA_INITIAL_OFFSET (newest, o padrão, ou oldest) e as variáveis
+// KAFKA_RETRY_* das retentativas e KAFKA_LEGACY_CUTOVER (RFC3339). O padrão newest evita que um grupo novo em
+// uma instalação existente reaplique as mensagens retidas; oldest precisa
+// ser pedido explicitamente.
+func LoadConsumerConfig() (ConsumerConfig, error) {
+	cfg := ConsumerConfig{
+		GroupID:       "banco-digital",
+		InitialOffset: sarama.OffsetNewest,
+	}
+	if v := os.Getenv("KAFKA_CONSUMER_GROUP"); v != "" {
+		cfg.GroupID = v
+	}
+	switch v := os.Getenv("KAFKA_INITIAL_OFFSET"); v {
+	case "", "newest":
+	case "oldest":
+		cfg.InitialOffset = sarama.OffsetOldest
+	default:
+		return cfg, fmt.Errorf("KAFKA_INITIAL_OFFSET inválido: %s (use oldest ou newest)", v)
+	}
+
+	if v := os.Getenv("KAFKA_LEGACY_CUTOVER"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return cfg, fmt.Errorf("KAFKA_LEGACY_CUTOVER inválido: %v", err)
+		}
+		cfg.LegacyCutover = t
+	}
+
+	retry, err := loadRetryConfig()
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Retry = retry
+	return cfg, nil
+}
+
+// GroupSubscriber é o Subscriber sobre os grupos de consumo do Kafka.
+type GroupSubscriber struct {
+	brokers []string
+	config  *sarama.Config
+
+	mu     sync.Mutex
+	groups []sarama.ConsumerGroup
+}
+
+func NewGroupSubscriber(client ClientConfig, cfg ConsumerConfig) (*GroupSubscriber, error) {
+	config, err := client.saramaConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.Initial = cfg.InitialOffset
+	config.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.BalanceStrategySticky}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &GroupSubscriber{
+		brokers: client.Brokers,
+		config:  config,
+	}, nil
+}
+
+// Subscribe entra no grupo e processa as partições atribuídas a esta réplica
+// até o contexto ser cancelado ou o Subscriber ser fechado.
+func (s *GroupSubscriber) Subscribe(ctx context.Context, group string, topics []string, handler MessageHandler) error {
+	consumerGroup, err := sarama.NewConsumerGroup(s.brokers, group, s.config)
+	if err != nil {
+		log.Printf("Failed to start consumer group %s: %v", group, err)
+		return err
+	}
+	s.mu.Lock()
+	s.groups = append(s.groups, consumerGroup)
+	s.mu.Unlock()
+
+	go func() {
+		for err := range consumerGroup.Errors() {
+			log.Printf("Error consuming messages in group %s: %v", group, err)
+		}
+	}()
+
+	h := groupHandler{group: group, handler: handler}
+	for {
+		// Consume retorna a cada rebalanceamento; chamar de novo volta ao grupo
+		if err := consumerGroup.Consume(ctx, topics, h); err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return nil
+			}
+			log.Printf("Consumer group error for group %s: %v", group, err)
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *GroupSubscriber) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var firstErr error
+	for _, group := range s.groups {
+		if err := group.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	s.groups = nil
+	return firstErr
+}
+
+// groupHandler entrega as mensagens das partições atribuídas à réplica e
+// marca cada uma para o commit automático de offsets depois de processada.
+// Se o handler falhar, a partição fica parada até o próximo
+// rebalanceamento, sem confirmar a mensagem.
+type groupHandler struct {
+	group   string
+	handler MessageHandler
+}
+
+func (h groupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	log.Printf("Consumer group %s joined: partitions %v", h.group, session.Claims())
+	return nil
+}
+
+func (h groupHandler) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h groupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			// Depois do cancelamento nenhuma mensagem nova é iniciada
+			if session.Context().Err() != nil {
+				return nil
+			}
+			if err := h.handler(session.Context(), messageFrom(msg)); err != nil {
+				return nil
+			}
+			session.MarkMessage(msg, "")
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+func messageFrom(msg *sarama.ConsumerMessage) *Message {
+	headers := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers[string(h.Key)] = string(h.Value)
+	}
+	return &Message{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Headers:   headers,
+		Timestamp: msg.Timestamp,
+	}
+}
@@ -0,0 +1,48 @@
+package kafka
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/red-velvet-workspace/banco-digital/internal/domain/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// claimEvent registra o evento como processado dentro da transação do
+// handler e devolve false se ele já tinha sido processado. Uma reentrega
+// concorrente fica bloqueada na chave primária até a primeira transação
+// terminar. Mensagens publicadas antes do EventID existir não têm registro e
+// são aplicadas, a menos que Projector.skipLegacy as descarte antes.
+func claimEvent(tx *gorm.DB, topic string, eventID uuid.UUID) (bool, error) {
+	if eventID == uuid.Nil {
+		return true, nil
+	}
+
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ProcessedEvent{
+		EventID:     eventID,
+		Topic:       topic,
+		ProcessedAt: time.Now(),
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		log.Printf("Duplicate event %s from topic %s skipped", eventID, topic)
+		return false, nil
+	}
+	return true, nil
+}
+
+// skipLegacy descarta mensagens sem EventID publicadas antes de
+// legacyCutover: a versão anterior já as aplicou, e reaplicá-las (um grupo
+// novo lendo desde oldest, por exemplo) duplicaria créditos e débitos. Sem
+// corte configurado, nada é descartado.
+func (p *Projector) skipLegacy(m *Message, eventID uuid.UUID) bool {
+	if eventID != uuid.Nil || p.legacyCutover.IsZero() || !m.Timestamp.Before(p.legacyCutover) {
+		return false
+	}
+	log.Printf("Legacy message from topic %s (partition %d, offset %d) before cutover skipped", m.Topic, m.Partition, m.Offset)
+	return true
+}
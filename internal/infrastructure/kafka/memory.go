@@ -0,0 +1,315 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// Partições de cada tópico do broker em memória
+const memoryPartitions = 3
+
+var errMemoryBrokerClosed = errors.New("broker em memória fechado")
+
+// MemoryBroker é um broker em processo com tópicos, partições e grupos de
+// consumo, que implementa Publisher e Subscriber sem depender do Kafka. As
+// mensagens são particionadas pelo hash da chave, como no Producer, e ficam
+// em memória enquanto o processo durar.
+type MemoryBroker struct {
+	partitions    int
+	initialOffset int64
+	name          string
+	codecs        Codecs
+
+	mu sync.Mutex
+	// changed é fechado e recriado a cada publicação ou mudança de grupo,
+	// acordando quem espera por mensagens ou por uma partição livre
+	changed     chan struct{}
+	closed      bool
+	topics      map[string][][]*Message
+	groups      map[string]*memoryGroup
+	nextMember  int
+	nextKeyless uint32
+}
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// memoryGroup guarda os membros de um grupo, na ordem de entrada, o próximo
+// offset de cada partição e o membro que está processando cada partição.
+// Cada entrada ou saída de membro gera uma nova geração e redistribui as
+// partições.
+type memoryGroup struct {
+	members    []int
+	generation int
+	offsets    map[topicPartition]int64
+	owners     map[topicPartition]int
+}
+
+// NewMemoryBroker cria o broker. initialOffset (sarama.OffsetOldest ou
+// sarama.OffsetNewest) define onde um grupo novo começa.
+func NewMemoryBroker(partitions int, initialOffset int64, codecs Codecs) *MemoryBroker {
+	return &MemoryBroker{
+		partitions:    partitions,
+		initialOffset: initialOffset,
+		name:          producerName(),
+		codecs:        codecs,
+		changed:       make(chan struct{}),
+		topics:        make(map[string][][]*Message),
+		groups:        make(map[string]*memoryGroup),
+	}
+}
+
+func (b *MemoryBroker) PublishMessage(topic string, key string, message interface{}) error {
+	value, contentType, err := encodeMessage(topic, key, message, b.name, b.codecs)
+	if err != nil {
+		return err
+	}
+	return b.Publish(topic, []byte(key), value, map[string]string{HeaderContentType: contentType})
+}
+
+func (b *MemoryBroker) Publish(topic string, key []byte, value []byte, headers map[string]string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return errMemoryBrokerClosed
+	}
+
+	partitions := b.topic(topic)
+	partition := b.partitionFor(key)
+	copied := make(map[string]string, len(headers))
+	for k, v := range headers {
+		copied[k] = v
+	}
+	partitions[partition] = append(partitions[partition], &Message{
+		Topic:     topic,
+		Partition: partition,
+		Offset:    int64(len(partitions[partition])),
+		Key:       key,
+		Value:     value,
+		Headers:   copied,
+		Timestamp: time.Now(),
+	})
+	b.broadcast()
+	return nil
+}
+
+// Subscribe entra no grupo e processa as partições atribuídas a este membro,
+// redistribuindo-as a cada entrada ou saída de membros.
+func (b *MemoryBroker) Subscribe(ctx context.Context, group string, topics []string, handler MessageHandler) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	for _, topic := range topics {
+		b.topic(topic)
+	}
+	g, ok := b.groups[group]
+	if !ok {
+		g = &memoryGroup{
+			offsets: make(map[topicPartition]int64),
+			owners:  make(map[topicPartition]int),
+		}
+		b.groups[group] = g
+	}
+	member := b.nextMember
+	b.nextMember++
+	g.members = append(g.members, member)
+	g.generation++
+	b.broadcast()
+	b.mu.Unlock()
+	defer b.leave(g, member)
+
+	for {
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			return nil
+		}
+		generation := g.generation
+		claims := b.assignment(g, member, topics)
+		changed := b.changed
+		b.mu.Unlock()
+
+		log.Printf("Memory consumer group %s joined: partitions %v", group, claims)
+		session, cancel := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		for _, tp := range claims {
+			wg.Add(1)
+			go func(tp topicPartition) {
+				defer wg.Done()
+				b.consumeClaim(session, g, member, tp, handler)
+			}(tp)
+		}
+
+		for rebalance := false; !rebalance; {
+			select {
+			case <-ctx.Done():
+				cancel()
+				wg.Wait()
+				return ctx.Err()
+			case <-changed:
+				b.mu.Lock()
+				rebalance = b.closed || g.generation != generation
+				changed = b.changed
+				b.mu.Unlock()
+			}
+		}
+		cancel()
+		wg.Wait()
+	}
+}
+
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		b.broadcast()
+	}
+	return nil
+}
+
+// consumeClaim entrega as mensagens da partição em ordem. A partição só é
+// assumida depois que o dono anterior termina a mensagem em andamento.
+func (b *MemoryBroker) consumeClaim(ctx context.Context, g *memoryGroup, member int, tp topicPartition, handler MessageHandler) {
+	if !b.acquire(ctx, g, member, tp) {
+		return
+	}
+	defer b.release(g, tp)
+
+	for ctx.Err() == nil {
+		b.mu.Lock()
+		messages := b.topics[tp.topic][tp.partition]
+		offset, ok := g.offsets[tp]
+		if !ok {
+			if b.initialOffset == sarama.OffsetNewest {
+				offset = int64(len(messages))
+			}
+			g.offsets[tp] = offset
+		}
+		if offset >= int64(len(messages)) {
+			changed := b.changed
+			b.mu.Unlock()
+			select {
+			case <-changed:
+			case <-ctx.Done():
+			}
+			continue
+		}
+		msg := *messages[offset]
+		b.mu.Unlock()
+
+		if err := handler(ctx, &msg); err != nil {
+			return
+		}
+		b.mu.Lock()
+		g.offsets[tp] = offset + 1
+		b.mu.Unlock()
+	}
+}
+
+func (b *MemoryBroker) acquire(ctx context.Context, g *memoryGroup, member int, tp topicPartition) bool {
+	b.mu.Lock()
+	for {
+		if owner, busy := g.owners[tp]; !busy || owner == member {
+			g.owners[tp] = member
+			b.mu.Unlock()
+			return true
+		}
+		changed := b.changed
+		b.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+		b.mu.Lock()
+	}
+}
+
+func (b *MemoryBroker) release(g *memoryGroup, tp topicPartition) {
+	b.mu.Lock()
+	delete(g.owners, tp)
+	b.broadcast()
+	b.mu.Unlock()
+}
+
+func (b *MemoryBroker) leave(g *memoryGroup, member int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, m := range g.members {
+		if m == member {
+			g.members = append(g.members[:i], g.members[i+1:]...)
+			break
+		}
+	}
+	g.generation++
+	b.broadcast()
+}
+
+// assignment distribui as partições dos tópicos entre os membros do grupo,
+// em rodízio pela ordem de entrada. Chamada com b.mu travado.
+func (b *MemoryBroker) assignment(g *memoryGroup, member int, topics []string) []topicPartition {
+	index := -1
+	for i, m := range g.members {
+		if m == member {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil
+	}
+
+	sorted := append([]string(nil), topics...)
+	sort.Strings(sorted)
+	var claims []topicPartition
+	n := 0
+	for _, topic := range sorted {
+		for p := range b.topics[topic] {
+			if n%len(g.members) == index {
+				claims = append(claims, topicPartition{topic: topic, partition: int32(p)})
+			}
+			n++
+		}
+	}
+	return claims
+}
+
+// topic devolve as partições do tópico, criando-o se preciso. Chamada com
+// b.mu travado.
+func (b *MemoryBroker) topic(name string) [][]*Message {
+	partitions, ok := b.topics[name]
+	if !ok {
+		partitions = make([][]*Message, b.partitions)
+		b.topics[name] = partitions
+	}
+	return partitions
+}
+
+// partitionFor escolhe a partição pelo hash da chave; mensagens sem chave são
+// distribuídas em rodízio. Chamada com b.mu travado.
+func (b *MemoryBroker) partitionFor(key []byte) int32 {
+	if key == nil {
+		b.nextKeyless++
+		return int32(b.nextKeyless % uint32(b.partitions))
+	}
+	h := fnv.New32a()
+	h.Write(key)
+	return int32(h.Sum32() % uint32(b.partitions))
+}
+
+// broadcast acorda quem espera em changed. Chamada com b.mu travado.
+func (b *MemoryBroker) broadcast() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
@@ -0,0 +1,228 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+const testTopic = "test-topic"
+
+// delivery registra uma mensagem entregue a um membro do grupo.
+type delivery struct {
+	member    string
+	key       string
+	seq       int
+	partition int32
+}
+
+// recorder guarda as entregas de todos os membros.
+type recorder struct {
+	mu         sync.Mutex
+	deliveries []delivery
+}
+
+func (r *recorder) handler(member string) MessageHandler {
+	return func(ctx context.Context, msg *Message) error {
+		seq, err := strconv.Atoi(string(msg.Value))
+		if err != nil {
+			return err
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.deliveries = append(r.deliveries, delivery{member: member, key: string(msg.Key), seq: seq, partition: msg.Partition})
+		return nil
+	}
+}
+
+func (r *recorder) snapshot() []delivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]delivery(nil), r.deliveries...)
+}
+
+// subscribe roda um membro do grupo até cancel ser chamado; done fecha
+// quando Subscribe retorna.
+func subscribe(t *testing.T, b *MemoryBroker, group string, handler MessageHandler) (cancel func(), done <-chan struct{}) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		b.Subscribe(ctx, group, []string{testTopic}, handler)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-finished
+	})
+	return cancel, finished
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("tempo esgotado esperando %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// owners devolve os membros que estão com alguma partição do tópico.
+func owners(b *MemoryBroker, group string) map[int]int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	counts := make(map[int]int)
+	if g, ok := b.groups[group]; ok {
+		for tp, member := range g.owners {
+			if tp.topic == testTopic {
+				counts[member]++
+			}
+		}
+	}
+	return counts
+}
+
+// initialized diz se todas as partições do tópico já têm offset no grupo.
+func initialized(b *MemoryBroker, group string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	g, ok := b.groups[group]
+	if !ok {
+		return false
+	}
+	n := 0
+	for tp := range g.offsets {
+		if tp.topic == testTopic {
+			n++
+		}
+	}
+	return n == b.partitions
+}
+
+func publish(t *testing.T, b *MemoryBroker, key string, seq int) {
+	t.Helper()
+	if err := b.Publish(testTopic, []byte(key), []byte(strconv.Itoa(seq)), nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryBrokerKeepsKeyOrder(t *testing.T) {
+	b := NewMemoryBroker(memoryPartitions, sarama.OffsetOldest, Codecs{})
+	defer b.Close()
+
+	rec := &recorder{}
+	subscribe(t, b, "ordering", rec.handler("a"))
+	subscribe(t, b, "ordering", rec.handler("b"))
+
+	const keys, perKey = 8, 50
+	for seq := 0; seq < perKey; seq++ {
+		for k := 0; k < keys; k++ {
+			publish(t, b, fmt.Sprintf("conta-%d", k), seq)
+		}
+	}
+	waitFor(t, "todas as mensagens", func() bool { return len(rec.snapshot()) >= keys*perKey })
+
+	next := make(map[string]int)
+	partitionOf := make(map[string]int32)
+	for _, d := range rec.snapshot() {
+		if p, ok := partitionOf[d.key]; ok && p != d.partition {
+			t.Errorf("chave %s em duas partições: %d e %d", d.key, p, d.partition)
+		}
+		partitionOf[d.key] = d.partition
+		if d.seq != next[d.key] {
+			t.Fatalf("chave %s: recebido %d, esperado %d", d.key, d.seq, next[d.key])
+		}
+		next[d.key]++
+	}
+}
+
+func TestMemoryBrokerRebalancesWhenMemberLeaves(t *testing.T) {
+	b := NewMemoryBroker(memoryPartitions, sarama.OffsetOldest, Codecs{})
+	defer b.Close()
+
+	rec := &recorder{}
+	cancelA, doneA := subscribe(t, b, "rebalance", rec.handler("a"))
+	subscribe(t, b, "rebalance", rec.handler("b"))
+	waitFor(t, "as partições divididas entre os dois membros", func() bool {
+		counts := owners(b, "rebalance")
+		total := 0
+		for _, n := range counts {
+			total += n
+		}
+		return len(counts) == 2 && total == memoryPartitions
+	})
+
+	cancelA()
+	<-doneA
+
+	// Chaves suficientes para cobrir todas as partições
+	const keys = 30
+	partitions := make(map[int32]bool)
+	for k := 0; k < keys; k++ {
+		key := fmt.Sprintf("conta-%d", k)
+		publish(t, b, key, k)
+		b.mu.Lock()
+		partitions[b.partitionFor([]byte(key))] = true
+		b.mu.Unlock()
+	}
+	if len(partitions) != memoryPartitions {
+		t.Fatalf("as chaves do teste cobrem %d de %d partições", len(partitions), memoryPartitions)
+	}
+
+	waitFor(t, "as mensagens publicadas depois da saída", func() bool { return len(rec.snapshot()) >= keys })
+	for _, d := range rec.snapshot() {
+		if d.member != "b" {
+			t.Errorf("mensagem %s/%d entregue ao membro que saiu", d.key, d.seq)
+		}
+	}
+}
+
+func TestMemoryBrokerInitialOffset(t *testing.T) {
+	tests := []struct {
+		name    string
+		initial int64
+		want    []int
+	}{
+		{"oldest", sarama.OffsetOldest, []int{0, 1, 2, 3}},
+		{"newest", sarama.OffsetNewest, []int{2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewMemoryBroker(memoryPartitions, tt.initial, Codecs{})
+			defer b.Close()
+
+			// A mesma chave mantém tudo na mesma partição: se as antigas
+			// fossem entregues, chegariam antes das novas
+			publish(t, b, "conta", 0)
+			publish(t, b, "conta", 1)
+
+			rec := &recorder{}
+			subscribe(t, b, "start-"+tt.name, rec.handler("a"))
+			waitFor(t, "o grupo assumir as partições", func() bool { return initialized(b, "start-"+tt.name) })
+
+			publish(t, b, "conta", 2)
+			publish(t, b, "conta", 3)
+			waitFor(t, "as mensagens novas", func() bool {
+				got := rec.snapshot()
+				return len(got) > 0 && got[len(got)-1].seq == 3
+			})
+
+			got := rec.snapshot()
+			if len(got) != len(tt.want) {
+				t.Fatalf("recebidas %d mensagens, esperado %d: %v", len(got), len(tt.want), got)
+			}
+			for i, d := range got {
+				if d.seq != tt.want[i] {
+					t.Errorf("mensagem %d: seq %d, esperado %d", i, d.seq, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -1,25 +1,92 @@
 package kafka
 
 import (
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/red-velvet-workspace/banco-digital/internal/audit"
     "github.com/red-velvet-workspace/banco-digital/internal/domain/models"
 )
 
+// Chaves de partição: mensagens com a mesma chave vão para a mesma partição
+// e são aplicadas na ordem em que foram publicadas. Cada tipo define a sua em
+// PartitionKey, e o Producer recusa mensagens publicadas com outra chave.
+//
+//   AccountMessage     ID da conta
+//   PIXKeyMessage      a própria chave PIX
+//   CreditCardMessage  ID do cartão
+//   TransactionMessage ID da conta movimentada
+type partitionKeyer interface {
+    PartitionKey() string
+}
+
+// As mensagens abaixo são a forma usada dentro do processo, com os modelos
+// do banco. No Kafka elas viajam dentro de um Envelope, com payloads próprios
+// (events.go) que não mudam junto com os modelos; as tags JSON destes tipos
+// são o formato da versão 1 do esquema, ainda aceito pelos consumidores.
+
+// Event identifica cada mensagem publicada. Os consumidores gravam o
+// EventID na mesma transação dos efeitos da mensagem e ignoram reentregas de
+// um evento já aplicado; o Producer recusa mensagens sem EventID.
+// CorrelationID e CausationID vazios são preenchidos na publicação com o ID
+// da requisição do ator.
+type Event struct {
+    EventID       uuid.UUID `json:"event_id"`
+    OccurredAt    time.Time `json:"-"`
+    CorrelationID string    `json:"-"`
+    CausationID   string    `json:"-"`
+}
+
+// NewEvent gera o identificador de uma nova mensagem.
+func NewEvent() Event {
+    return Event{EventID: uuid.New(), OccurredAt: time.Now()}
+}
+
 type AccountMessage struct {
+    Event
     Operation string         `json:"operation"` // CREATE, UPDATE
     Account   models.Account `json:"account"`
+    Actor     audit.Actor    `json:"actor"`
+}
+
+func (m AccountMessage) PartitionKey() string {
+    return m.Account.ID.String()
 }
 
 type PIXKeyMessage struct {
+    Event
     Operation string        `json:"operation"` // CREATE, DELETE
     PIXKey    models.PIXKey `json:"pix_key"`
+    Actor     audit.Actor   `json:"actor"`
+}
+
+// PartitionKey usa a chave PIX para que cadastro e exclusão da mesma chave,
+// inclusive por contas diferentes, sejam aplicados em ordem.
+func (m PIXKeyMessage) PartitionKey() string {
+    return m.PIXKey.Key
 }
 
 type CreditCardMessage struct {
-    Operation   string            `json:"operation"` // CREATE, UPDATE
+    Event
+    Operation   string            `json:"operation"` // CREATE, UPDATE, UPDATE_LIMIT
     CreditCard  models.CreditCard `json:"credit_card"`
+    Actor       audit.Actor       `json:"actor"`
+}
+
+func (m CreditCardMessage) PartitionKey() string {
+    return m.CreditCard.ID.String()
 }
 
 type TransactionMessage struct {
-    Operation   string             `json:"operation"`
+    Event
+    Operation   string             `json:"operation"` // CREATE, AUTHORIZE, VOID, EXPIRE
     Transaction models.Transaction `json:"transaction"`
+    ExpiresAt   time.Time          `json:"expires_at,omitempty"` // AUTHORIZE
+    Actor       audit.Actor        `json:"actor"`
+}
+
+// PartitionKey usa a conta para que saques, compras e pagamentos da mesma
+// conta nunca sejam aplicados fora de ordem.
+func (m TransactionMessage) PartitionKey() string {
+    return m.Transaction.AccountID.String()
 }
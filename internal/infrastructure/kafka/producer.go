@@ -1,7 +1,6 @@
 package kafka
 
 import (
-    "encoding/json"
     "log"
 
     "github.com/Shopify/sarama"
@@ -9,33 +8,54 @@ import (
 
 type Producer struct {
     producer sarama.SyncProducer
+    name     string
+    codecs   Codecs
 }
 
-func NewProducer(brokers []string) (*Producer, error) {
-    config := sarama.NewConfig()
+// NewProducer cria o produtor. codecs escolhe a serialização dos eventos de
+// cada tópico.
+func NewProducer(client ClientConfig, codecs Codecs) (*Producer, error) {
+    config, err := client.saramaConfig()
+    if err != nil {
+        return nil, err
+    }
     config.Producer.RequiredAcks = sarama.WaitForAll
     config.Producer.Retry.Max = 5
     config.Producer.Return.Successes = true
+    // A partição vem do hash da chave; o produtor idempotente com uma
+    // requisição em voo por broker mantém a ordem mesmo com retentativas
+    config.Producer.Partitioner = sarama.NewHashPartitioner
+    config.Producer.Idempotent = true
+    config.Net.MaxOpenRequests = 1
 
-    producer, err := sarama.NewSyncProducer(brokers, config)
+    producer, err := sarama.NewSyncProducer(client.Brokers, config)
     if err != nil {
         return nil, err
     }
 
     return &Producer{
         producer: producer,
+        name:     producerName(),
+        codecs:   codecs,
     }, nil
 }
 
-func (p *Producer) PublishMessage(topic string, message interface{}) error {
-    json, err := json.Marshal(message)
+// PublishMessage publica a mensagem com a chave de partição key. As
+// mensagens de messages.go precisam usar a chave do próprio PartitionKey e
+// são publicadas dentro de um Envelope.
+func (p *Producer) PublishMessage(topic string, key string, message interface{}) error {
+    value, contentType, err := encodeMessage(topic, key, message, p.name, p.codecs)
     if err != nil {
         return err
     }
 
     msg := &sarama.ProducerMessage{
         Topic: topic,
-        Value: sarama.StringEncoder(json),
+        Key:   sarama.StringEncoder(key),
+        Value: sarama.ByteEncoder(value),
+        Headers: []sarama.RecordHeader{
+            {Key: []byte(HeaderContentType), Value: []byte(contentType)},
+        },
     }
 
     partition, offset, err := p.producer.SendMessage(msg)
@@ -48,6 +68,24 @@ func (p *Producer) PublishMessage(topic string, message interface{}) error {
     return nil
 }
 
+// Publish publica uma mensagem já serializada, preservando chave e
+// cabeçalhos. É usada no encaminhamento para retentativa, DLQ e reenvio.
+func (p *Producer) Publish(topic string, key []byte, value []byte, headers map[string]string) error {
+    msg := &sarama.ProducerMessage{
+        Topic: topic,
+        Value: sarama.ByteEncoder(value),
+    }
+    if key != nil {
+        msg.Key = sarama.ByteEncoder(key)
+    }
+    for k, v := range headers {
+        msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(k), Value: []byte(v)})
+    }
+
+    _, _, err := p.producer.SendMessage(msg)
+    return err
+}
+
 func (p *Producer) Close() error {
     return p.producer.Close()
 }
@@ -0,0 +1,40 @@
+package kafka
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Projector aplica as mensagens dos tópicos no banco. O Consumer o usa com o
+// banco da API e o cmd/replay com o banco a reconstruir; dentro de uma
+// transação do gorm, cada mensagem vira um savepoint.
+type Projector struct {
+	db            *gorm.DB
+	legacyCutover time.Time
+}
+
+// ProjectedTopics são os tópicos que o Projector sabe aplicar, na ordem em
+// que dependem uns dos outros.
+var ProjectedTopics = []string{TopicAccounts, TopicPIX, TopicCreditCards, TopicTransactions}
+
+func NewProjector(db *gorm.DB) *Projector {
+	return &Projector{db: db}
+}
+
+// Apply aplica uma mensagem de qualquer um dos tópicos.
+func (p *Projector) Apply(msg *Message) error {
+	switch msg.Topic {
+	case TopicAccounts:
+		return p.applyAccount(msg)
+	case TopicPIX:
+		return p.applyPIXKey(msg)
+	case TopicCreditCards:
+		return p.applyCreditCard(msg)
+	case TopicTransactions:
+		return p.applyTransaction(msg)
+	default:
+		return fmt.Errorf("tópico sem projeção: %s", msg.Topic)
+	}
+}
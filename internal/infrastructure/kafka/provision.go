@@ -0,0 +1,101 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// LoadTopics devolve os tópicos de Topics com o fator de replicação de
+// KAFKA_REPLICATION_FACTOR (padrão 1, o do docker-compose).
+func LoadTopics() ([]TopicSpec, error) {
+	replicationFactor := int16(1)
+	if v := os.Getenv("KAFKA_REPLICATION_FACTOR"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 16)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("KAFKA_REPLICATION_FACTOR inválido: %s", v)
+		}
+		replicationFactor = int16(n)
+	}
+	return Topics(replicationFactor), nil
+}
+
+// EnsureTopics cria pela API de administração os tópicos de specs que ainda
+// não existem e confere os demais. Partições, replicação ou retenção
+// diferentes do declarado são devolvidas como erro, sem alterar o tópico:
+// reduzir partições não é possível e aumentá-las muda a partição das chaves,
+// então a correção fica a cargo de quem opera o cluster.
+func EnsureTopics(cfg ClientConfig, specs []TopicSpec) error {
+	config, err := cfg.saramaConfig()
+	if err != nil {
+		return err
+	}
+	admin, err := sarama.NewClusterAdmin(cfg.Brokers, config)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	existing, err := admin.ListTopics()
+	if err != nil {
+		return err
+	}
+
+	var mismatches []string
+	for _, spec := range specs {
+		detail, ok := existing[spec.Name]
+		if !ok {
+			err := admin.CreateTopic(spec.Name, &sarama.TopicDetail{
+				NumPartitions:     spec.Partitions,
+				ReplicationFactor: spec.ReplicationFactor,
+				ConfigEntries: map[string]*string{
+					"retention.ms": retentionMs(spec.Retention),
+				},
+			}, false)
+			// Outra réplica pode ter criado o tópico ao mesmo tempo
+			if errors.Is(err, sarama.ErrTopicAlreadyExists) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("erro ao criar o tópico %s: %v", spec.Name, err)
+			}
+			log.Printf("Created topic %s (partitions=%d, replication=%d)", spec.Name, spec.Partitions, spec.ReplicationFactor)
+			continue
+		}
+
+		if detail.NumPartitions != spec.Partitions {
+			mismatches = append(mismatches, fmt.Sprintf("%s: %d partição(ões), esperado %d", spec.Name, detail.NumPartitions, spec.Partitions))
+		}
+		if detail.ReplicationFactor != spec.ReplicationFactor {
+			mismatches = append(mismatches, fmt.Sprintf("%s: replicação %d, esperado %d", spec.Name, detail.ReplicationFactor, spec.ReplicationFactor))
+		}
+		// ListTopics omite as configurações com o valor padrão do broker
+		want := *retentionMs(spec.Retention)
+		got := "padrão do broker"
+		if v := detail.ConfigEntries["retention.ms"]; v != nil {
+			got = *v
+		}
+		if got != want {
+			mismatches = append(mismatches, fmt.Sprintf("%s: retention.ms %s, esperado %s", spec.Name, got, want))
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("tópicos Kafka divergentes do declarado:\n  %s", strings.Join(mismatches, "\n  "))
+	}
+	return nil
+}
+
+func retentionMs(retention time.Duration) *string {
+	v := "-1"
+	if retention != RetentionForever {
+		v = strconv.FormatInt(retention.Milliseconds(), 10)
+	}
+	return &v
+}
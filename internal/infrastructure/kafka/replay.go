@@ -0,0 +1,177 @@
+package kafka
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// ReplayFrom define de onde a releitura começa em cada partição: do offset
+// informado ou, quando Since não é zero, da primeira mensagem publicada a
+// partir de Since. Offset negativo começa da mensagem mais antiga retida.
+type ReplayFrom struct {
+	Offset int64
+	Since  time.Time
+}
+
+// Replay relê tópicos do início (ou de um ponto escolhido) até o fim que
+// eles tinham ao abrir a releitura, sem grupo de consumo e sem confirmar
+// offsets.
+type Replay struct {
+	client   sarama.Client
+	consumer sarama.Consumer
+}
+
+// OpenReplay conecta aos brokers para reler tópicos.
+func OpenReplay(cfg ClientConfig) (*Replay, error) {
+	config, err := cfg.saramaConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := sarama.NewClient(cfg.Brokers, config)
+	if err != nil {
+		return nil, err
+	}
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &Replay{client: client, consumer: consumer}, nil
+}
+
+// Read entrega a fn as mensagens de todos os tópicos em ordem de publicação,
+// intercalando as partições pelo timestamp; empates seguem a ordem de
+// topics. Um erro de fn interrompe a leitura.
+func (r *Replay) Read(topics []string, from ReplayFrom, fn func(*Message) error) error {
+	var cursors []*replayCursor
+	defer func() {
+		for _, cursor := range cursors {
+			cursor.pc.Close()
+		}
+	}()
+
+	for rank, topic := range topics {
+		partitions, err := r.client.Partitions(topic)
+		if err != nil {
+			return err
+		}
+		for _, partition := range partitions {
+			start, end, err := r.bounds(topic, partition, from)
+			if err != nil {
+				return err
+			}
+			if start >= end {
+				continue
+			}
+			pc, err := r.consumer.ConsumePartition(topic, partition, start)
+			if err != nil {
+				return err
+			}
+			cursor := &replayCursor{topic: topic, partition: partition, rank: rank, pc: pc, last: start - 1, end: end}
+			cursors = append(cursors, cursor)
+			if err := cursor.next(); err != nil {
+				return err
+			}
+		}
+	}
+
+	for {
+		var head *replayCursor
+		for _, cursor := range cursors {
+			if cursor.msg == nil {
+				continue
+			}
+			if head == nil || cursor.before(head) {
+				head = cursor
+			}
+		}
+		if head == nil {
+			return nil
+		}
+
+		if err := fn(messageFrom(head.msg)); err != nil {
+			return err
+		}
+		if err := head.next(); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *Replay) Close() error {
+	r.consumer.Close()
+	return r.client.Close()
+}
+
+// bounds devolve o primeiro offset a ler e o offset logo após a última
+// mensagem da partição no momento da chamada.
+func (r *Replay) bounds(topic string, partition int32, from ReplayFrom) (int64, int64, error) {
+	oldest, err := r.client.GetOffset(topic, partition, sarama.OffsetOldest)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := r.client.GetOffset(topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	start := oldest
+	switch {
+	case !from.Since.IsZero():
+		start, err = r.client.GetOffset(topic, partition, from.Since.UnixMilli())
+		if err != nil {
+			return 0, 0, err
+		}
+		// Sem mensagens depois de Since o broker devolve -1
+		if start < 0 {
+			start = end
+		}
+	case from.Offset > oldest:
+		start = from.Offset
+	}
+	return start, end, nil
+}
+
+// replayCursor guarda a próxima mensagem ainda não entregue de uma partição.
+type replayCursor struct {
+	topic     string
+	partition int32
+	rank      int
+	pc        sarama.PartitionConsumer
+	last      int64
+	end       int64
+	msg       *sarama.ConsumerMessage
+}
+
+// next avança para a mensagem seguinte, ou deixa msg nil ao chegar em end.
+func (c *replayCursor) next() error {
+	if c.msg != nil {
+		c.last = c.msg.Offset
+	}
+	c.msg = nil
+	if c.last+1 >= c.end {
+		return nil
+	}
+
+	select {
+	case msg := <-c.pc.Messages():
+		if msg.Offset >= c.end {
+			return nil
+		}
+		c.msg = msg
+		return nil
+	case err := <-c.pc.Errors():
+		return err
+	case <-time.After(10 * time.Second):
+		return fmt.Errorf("tempo esgotado lendo %s/%d depois do offset %d", c.topic, c.partition, c.last)
+	}
+}
+
+func (c *replayCursor) before(other *replayCursor) bool {
+	if !c.msg.Timestamp.Equal(other.msg.Timestamp) {
+		return c.msg.Timestamp.Before(other.msg.Timestamp)
+	}
+	return c.rank < other.rank
+}
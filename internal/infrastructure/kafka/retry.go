@@ -0,0 +1,165 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Cabeçalhos das mensagens encaminhadas para os tópicos de retentativa e de
+// mensagens mortas (DLQ).
+const (
+	HeaderError             = "x-error"
+	HeaderAttempts          = "x-attempts"
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderRetryAt           = "x-retry-at"
+	HeaderRedrivenFrom      = "x-redriven-from"
+)
+
+// RetryTopic é o tópico de retentativas de topic.
+func RetryTopic(topic string) string {
+	return topic + ".retry"
+}
+
+// DeadLetterTopic é o tópico de mensagens mortas de topic.
+func DeadLetterTopic(topic string) string {
+	return topic + ".dlq"
+}
+
+// RetryConfig define quantas vezes uma mensagem é processada antes de ir
+// para a DLQ e o intervalo entre as tentativas, que dobra a cada falha até
+// MaxBackoff.
+type RetryConfig struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+func loadRetryConfig() (RetryConfig, error) {
+	cfg := RetryConfig{
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+	}
+	if v := os.Getenv("KAFKA_RETRY_MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return cfg, fmt.Errorf("KAFKA_RETRY_MAX_ATTEMPTS inválido: %s", v)
+		}
+		cfg.MaxAttempts = n
+	}
+
+	durations := map[string]*time.Duration{
+		"KAFKA_RETRY_BACKOFF":     &cfg.InitialBackoff,
+		"KAFKA_RETRY_MAX_BACKOFF": &cfg.MaxBackoff,
+	}
+	for name, dst := range durations {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("%s inválido: %v", name, err)
+		}
+		*dst = d
+	}
+	return cfg, nil
+}
+
+// backoff é a espera antes da tentativa seguinte à falha número attempts.
+func (r RetryConfig) backoff(attempts int) time.Duration {
+	d := r.InitialBackoff
+	for i := 1; i < attempts && d < r.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.MaxBackoff {
+		d = r.MaxBackoff
+	}
+	return d
+}
+
+// forwardFailure encaminha a mensagem que falhou para o tópico de
+// retentativa ou, esgotadas as tentativas, para a DLQ. Enquanto o
+// encaminhamento falhar a partição fica parada, pois a mensagem não pode ser
+// confirmada sem estar em algum dos tópicos. Devolve false se o contexto
+// acabar antes.
+func (c *Consumer) forwardFailure(ctx context.Context, topic string, msg *Message, cause error) bool {
+	attempts := headerInt(msg, HeaderAttempts) + 1
+	headers := map[string]string{
+		HeaderError:             cause.Error(),
+		HeaderAttempts:          strconv.Itoa(attempts),
+		HeaderOriginalTopic:     topic,
+		HeaderOriginalPartition: headerOr(msg, HeaderOriginalPartition, strconv.Itoa(int(msg.Partition))),
+		HeaderOriginalOffset:    headerOr(msg, HeaderOriginalOffset, strconv.FormatInt(msg.Offset, 10)),
+	}
+	if contentType := header(msg, HeaderContentType); contentType != "" {
+		headers[HeaderContentType] = contentType
+	}
+
+	dest := DeadLetterTopic(topic)
+	if attempts < c.retry.MaxAttempts {
+		dest = RetryTopic(topic)
+		retryAt := time.Now().Add(c.retry.backoff(attempts))
+		headers[HeaderRetryAt] = retryAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	for wait := c.retry.InitialBackoff; ; {
+		err := c.publisher.Publish(dest, msg.Key, msg.Value, headers)
+		if err == nil {
+			log.Printf("Message from topic %s forwarded to %s after %d attempt(s): %v", topic, dest, attempts, cause)
+			return true
+		}
+		log.Printf("Failed to forward message from topic %s to %s: %v", topic, dest, err)
+		if !sleepCtx(ctx, wait) {
+			return false
+		}
+		if wait *= 2; wait > c.retry.MaxBackoff {
+			wait = c.retry.MaxBackoff
+		}
+	}
+}
+
+// waitRetry espera até o horário de retentativa gravado na mensagem.
+func waitRetry(ctx context.Context, msg *Message) bool {
+	retryAt, err := time.Parse(time.RFC3339Nano, header(msg, HeaderRetryAt))
+	if err != nil {
+		return true
+	}
+	return sleepCtx(ctx, time.Until(retryAt))
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func header(msg *Message, key string) string {
+	return msg.Headers[key]
+}
+
+func headerOr(msg *Message, key string, fallback string) string {
+	if v := header(msg, key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func headerInt(msg *Message, key string) int {
+	n, _ := strconv.Atoi(header(msg, key))
+	return n
+}
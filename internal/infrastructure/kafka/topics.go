@@ -1,8 +1,37 @@
 package kafka
 
+import "time"
+
 const (
     TopicAccounts     = "accounts"
     TopicPIX         = "pix-keys"
     TopicCreditCards = "credit-cards"
     TopicTransactions = "transactions"
 )
+
+// RetentionForever mantém as mensagens do tópico indefinidamente.
+const RetentionForever time.Duration = -1
+
+// TopicSpec declara como um tópico deve existir no cluster. EnsureTopics
+// cria os que faltam e recusa os que existem com outra configuração.
+type TopicSpec struct {
+    Name              string
+    Partitions        int32
+    ReplicationFactor int16
+    Retention         time.Duration
+}
+
+// Topics são os tópicos usados pelo serviço. Os tópicos principais guardam
+// todo o histórico para o cmd/replay conseguir reconstruir o banco; os de
+// retentativa só precisam durar até a mensagem ser reprocessada.
+func Topics(replicationFactor int16) []TopicSpec {
+    var specs []TopicSpec
+    for _, topic := range ProjectedTopics {
+        specs = append(specs,
+            TopicSpec{Name: topic, Partitions: 3, ReplicationFactor: replicationFactor, Retention: RetentionForever},
+            TopicSpec{Name: RetryTopic(topic), Partitions: 3, ReplicationFactor: replicationFactor, Retention: 7 * 24 * time.Hour},
+            TopicSpec{Name: DeadLetterTopic(topic), Partitions: 1, ReplicationFactor: replicationFactor, Retention: 30 * 24 * time.Hour},
+        )
+    }
+    return specs
+}
@@ -0,0 +1,137 @@
+// Package lifecycle coordena o encerramento do processo: ao receber SIGINT ou
+// SIGTERM, ou quando uma tarefa de fundo falha, executa as etapas de parada
+// na ordem inversa do registro e dentro de um prazo único.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// LoadShutdownTimeout lê SHUTDOWN_TIMEOUT (padrão 30s), o prazo total para
+// o encerramento.
+func LoadShutdownTimeout() (time.Duration, error) {
+	timeout := 30 * time.Second
+	if v := os.Getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return 0, fmt.Errorf("SHUTDOWN_TIMEOUT inválido: %s", v)
+		}
+		timeout = d
+	}
+	return timeout, nil
+}
+
+type stopHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Manager guarda as tarefas de fundo e as etapas de parada do processo.
+type Manager struct {
+	timeout time.Duration
+
+	mu     sync.Mutex
+	hooks  []stopHook
+	failed chan error
+}
+
+func New(timeout time.Duration) *Manager {
+	return &Manager{
+		timeout: timeout,
+		failed:  make(chan error, 1),
+	}
+}
+
+// OnStop registra uma etapa de parada. As etapas rodam na ordem inversa do
+// registro, então o que foi criado primeiro é encerrado por último.
+func (m *Manager) OnStop(name string, fn func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, stopHook{name: name, fn: fn})
+}
+
+// Go roda fn em uma goroutine com um contexto próprio e registra a etapa de
+// parada que cancela esse contexto e espera fn retornar. Um erro de fn antes
+// do cancelamento encerra o processo.
+func (m *Manager) Go(name string, fn func(ctx context.Context) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		err := fn(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = errors.New("terminou inesperadamente")
+		}
+		log.Printf("[ERROR] %s: %v", name, err)
+		select {
+		case m.failed <- fmt.Errorf("%s: %w", name, err):
+		default:
+		}
+	}()
+
+	m.OnStop(name, func(stopCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-stopCtx.Done():
+			return stopCtx.Err()
+		}
+	})
+}
+
+// Run bloqueia até um sinal de término ou a falha de uma tarefa e então
+// executa as etapas de parada. Devolve a falha que motivou o encerramento,
+// se houver, junto com os erros das etapas.
+func (m *Manager) Run() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	var cause error
+	select {
+	case sig := <-signals:
+		log.Printf("[INFO] Sinal %v recebido, encerrando", sig)
+	case cause = <-m.failed:
+		log.Printf("[ERROR] Encerrando por falha: %v", cause)
+	}
+
+	return errors.Join(cause, m.Stop())
+}
+
+// Stop executa as etapas de parada dentro do prazo. Uma etapa que falha ou
+// estoura o prazo não impede as seguintes.
+func (m *Manager) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	m.mu.Lock()
+	hooks := m.hooks
+	m.hooks = nil
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hook := hooks[i]
+		start := time.Now()
+		if err := hook.fn(ctx); err != nil {
+			log.Printf("[ERROR] Erro ao encerrar %s: %v", hook.name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", hook.name, err))
+			continue
+		}
+		log.Printf("[INFO] %s encerrado em %v", hook.name, time.Since(start).Round(time.Millisecond))
+	}
+	return errors.Join(errs...)
+}
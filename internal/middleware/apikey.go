@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/red-velvet-workspace/banco-digital/internal/audit"
+	"github.com/red-velvet-workspace/banco-digital/internal/auth"
+)
+
+// APIKeyAuthenticator valida as chaves de API recebidas no cabeçalho
+// X-API-Key. ip é o IP de origem, conferido com as restrições da chave.
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string, ip string) (*auth.Principal, error)
+}
+
+var apiKeyAuthenticator APIKeyAuthenticator
+
+// InitAPIKeys habilita a autenticação por chave de API.
+func InitAPIKeys(a APIKeyAuthenticator) {
+	apiKeyAuthenticator = a
+}
+
+// RequireScope aceita o JWT de um usuário ou uma chave de API que tenha o
+// escopo da rota. Para usuários a rota segue as regras de posse de conta.
+func RequireScope(scope auth.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, status, message := authenticate(r)
+		if principal == nil {
+			http.Error(w, message, status)
+			return
+		}
+		if principal.APIKeyID != nil && !principal.Can(scope) {
+			http.Error(w, "Escopo insuficiente", http.StatusForbidden)
+			return
+		}
+		serveWithPrincipal(w, r, principal, next)
+	}
+}
+
+func authenticateAPIKey(r *http.Request, key string) (*auth.Principal, int, string) {
+	if apiKeyAuthenticator == nil {
+		return nil, http.StatusUnauthorized, "API keys are not enabled"
+	}
+	principal, err := apiKeyAuthenticator.Authenticate(r.Context(), key, audit.IP(r.Context()))
+	if err != nil {
+		log.Printf("[INFO] Chave de API recusada: %v", err)
+		return nil, http.StatusUnauthorized, "Invalid API key"
+	}
+	return principal, 0, ""
+}
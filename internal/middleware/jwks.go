@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// jwk é uma chave pública no formato JSON Web Key (RFC 7517). Apenas chaves
+// RSA e EC (P-256, P-384, P-521) são suportadas.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// KeySet guarda as chaves públicas de um JWKS indexadas pelo kid. As chaves
+// são recarregadas quando chega um token com kid desconhecido, o que permite
+// rotacionar chaves sem reiniciar o serviço.
+type KeySet struct {
+	file       string
+	url        string
+	minRefresh time.Duration
+	client     *http.Client
+
+	mu          sync.RWMutex
+	keys        map[string]interface{}
+	lastRefresh time.Time
+}
+
+// NewKeySet carrega o JWKS de um arquivo ou de uma URL. minRefresh limita a
+// frequência de recarga provocada por kids desconhecidos.
+func NewKeySet(file, url string, minRefresh time.Duration) (*KeySet, error) {
+	ks := &KeySet{
+		file:       file,
+		url:        url,
+		minRefresh: minRefresh,
+		client:     &http.Client{Timeout: 5 * time.Second},
+	}
+	if err := ks.Refresh(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Refresh recarrega as chaves da origem configurada.
+func (ks *KeySet) Refresh() error {
+	data, err := ks.read()
+	if err != nil {
+		return err
+	}
+
+	var set jwkSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("JWKS inválido: %v", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return fmt.Errorf("chave %q do JWKS inválida: %v", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.lastRefresh = time.Now()
+	ks.mu.Unlock()
+	return nil
+}
+
+// Key devolve a chave do kid informado. Se o kid for desconhecido o JWKS é
+// recarregado, respeitando o intervalo mínimo entre recargas.
+func (ks *KeySet) Key(kid string) (interface{}, error) {
+	ks.mu.RLock()
+	key, ok := ks.keys[kid]
+	stale := time.Since(ks.lastRefresh) >= ks.minRefresh
+	ks.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if stale {
+		if err := ks.Refresh(); err != nil {
+			log.Printf("[ERROR] Erro ao recarregar JWKS: %v", err)
+		} else {
+			ks.mu.RLock()
+			key, ok = ks.keys[kid]
+			ks.mu.RUnlock()
+			if ok {
+				return key, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("chave de assinatura %q desconhecida", kid)
+}
+
+func (ks *KeySet) read() ([]byte, error) {
+	if ks.file != "" {
+		return os.ReadFile(ks.file)
+	}
+
+	resp, err := ks.client.Get(ks.url)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar JWKS: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("erro ao buscar JWKS: status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("curva %q não suportada", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("ponto fora da curva %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("tipo de chave %q não suportado", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
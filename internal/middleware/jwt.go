@@ -1,49 +1,257 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/red-velvet-workspace/banco-digital/internal/audit"
+	"github.com/red-velvet-workspace/banco-digital/internal/auth"
 )
 
-func JWTMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Get the Authorization header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header is required", http.StatusUnauthorized)
-			return
-		}
+// JWTConfig define como os tokens são verificados. Tokens HS256 usam
+// HMACSecret; tokens RS256/ES256 usam as chaves do JWKS (arquivo ou URL),
+// escolhidas pelo kid do cabeçalho. Os TTLs valem para os tokens emitidos
+// pelo próprio serviço em /auth.
+type JWTConfig struct {
+	HMACSecret      []byte
+	JWKSFile        string
+	JWKSURL         string
+	Issuer          string
+	Audience        string
+	ClockSkew       time.Duration
+	JWKSMinRefresh  time.Duration
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
 
-		// Check if the header starts with "Bearer "
-		bearerToken := strings.Split(authHeader, " ")
-		if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
-			http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
-			return
+// Segredos de exemplo que já circularam no repositório e nos scripts; quem
+// os conhece consegue assinar tokens válidos.
+var knownPlaceholderSecrets = []string{"your-256-bit-secret", "secret", "changeme"}
+
+// Tamanho mínimo do segredo HS256, o mesmo da saída do SHA-256
+const minHMACSecretLen = 32
+
+// LoadJWTConfig lê a configuração das variáveis de ambiente JWT_*.
+// JWT_ISSUER e JWT_AUDIENCE são obrigatórios, e o segredo HS256, quando
+// informado, precisa ter ao menos 32 bytes e não pode ser um segredo de
+// exemplo.
+func LoadJWTConfig() (JWTConfig, error) {
+	cfg := JWTConfig{
+		HMACSecret:      []byte(os.Getenv("JWT_HMAC_SECRET")),
+		JWKSFile:        os.Getenv("JWT_JWKS_FILE"),
+		JWKSURL:         os.Getenv("JWT_JWKS_URL"),
+		Issuer:          os.Getenv("JWT_ISSUER"),
+		Audience:        os.Getenv("JWT_AUDIENCE"),
+		ClockSkew:       30 * time.Second,
+		JWKSMinRefresh:  time.Minute,
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 30 * 24 * time.Hour,
+	}
+
+	durations := map[string]*time.Duration{
+		"JWT_CLOCK_SKEW":       &cfg.ClockSkew,
+		"JWT_JWKS_MIN_REFRESH": &cfg.JWKSMinRefresh,
+		"JWT_ACCESS_TTL":       &cfg.AccessTokenTTL,
+		"JWT_REFRESH_TTL":      &cfg.RefreshTokenTTL,
+	}
+	for name, dst := range durations {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
 		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("%s inválido: %v", name, err)
+		}
+		*dst = d
+	}
 
-		// Parse and validate the token
-		token, err := jwt.Parse(bearerToken[1], func(token *jwt.Token) (interface{}, error) {
-			// Verify signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return cfg, errors.New("JWT_ISSUER e JWT_AUDIENCE são obrigatórios")
+	}
+	if len(cfg.HMACSecret) > 0 {
+		for _, placeholder := range knownPlaceholderSecrets {
+			if string(cfg.HMACSecret) == placeholder {
+				return cfg, errors.New("JWT_HMAC_SECRET usa um segredo de exemplo público; gere um novo (ex.: openssl rand -base64 32)")
 			}
-			// Return the secret key used to sign the token
-			return []byte("your-256-bit-secret"), nil
-		})
+		}
+		if len(cfg.HMACSecret) < minHMACSecretLen {
+			return cfg, fmt.Errorf("JWT_HMAC_SECRET precisa ter ao menos %d bytes", minHMACSecretLen)
+		}
+	}
+	return cfg, nil
+}
+
+// JWTVerifier valida assinatura, algoritmo e as claims iss, aud, exp e nbf.
+type JWTVerifier struct {
+	hmacSecret []byte
+	keys       *KeySet
+	parser     *jwt.Parser
+}
 
+func NewJWTVerifier(cfg JWTConfig) (*JWTVerifier, error) {
+	v := &JWTVerifier{hmacSecret: cfg.HMACSecret}
+
+	var methods []string
+	if len(cfg.HMACSecret) > 0 {
+		methods = append(methods, "HS256")
+	}
+	if cfg.JWKSFile != "" || cfg.JWKSURL != "" {
+		keys, err := NewKeySet(cfg.JWKSFile, cfg.JWKSURL, cfg.JWKSMinRefresh)
 		if err != nil {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
+			return nil, err
+		}
+		v.keys = keys
+		methods = append(methods, "RS256", "ES256")
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("nenhuma chave de verificação JWT configurada (JWT_HMAC_SECRET, JWT_JWKS_FILE ou JWT_JWKS_URL)")
+	}
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return nil, errors.New("JWT_ISSUER e JWT_AUDIENCE são obrigatórios")
+	}
+
+	v.parser = jwt.NewParser(
+		jwt.WithValidMethods(methods),
+		jwt.WithLeeway(cfg.ClockSkew),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuer(cfg.Issuer),
+		jwt.WithAudience(cfg.Audience),
+	)
+	return v, nil
+}
+
+// Parse valida o token e devolve suas claims.
+func (v *JWTVerifier) Parse(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	token, err := v.parser.ParseWithClaims(tokenString, claims, v.keyFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+	// O parser só valida nbf quando presente; aqui ele é obrigatório
+	if _, ok := claims["nbf"]; !ok {
+		return nil, jwt.ErrTokenRequiredClaimMissing
+	}
+	return claims, nil
+}
+
+func (v *JWTVerifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return v.hmacSecret, nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("token sem kid")
 		}
+		return v.keys.Key(kid)
+	}
+	return nil, jwt.ErrSignatureInvalid
+}
 
-		if !token.Valid {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+var jwtVerifier *JWTVerifier
+
+// InitJWT configura o verificador usado por JWTMiddleware.
+func InitJWT(cfg JWTConfig) error {
+	v, err := NewJWTVerifier(cfg)
+	if err != nil {
+		return err
+	}
+	jwtVerifier = v
+	return nil
+}
+
+func JWTMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, status, message := authenticate(r)
+		if principal == nil {
+			http.Error(w, message, status)
 			return
 		}
+		if principal.APIKeyID != nil {
+			http.Error(w, "Chave de API sem acesso a esta rota", http.StatusForbidden)
+			return
+		}
+
+		// Token is valid, proceed with the caller identity in the context
+		serveWithPrincipal(w, r, principal, next)
+	}
+}
+
+func serveWithPrincipal(w http.ResponseWriter, r *http.Request, principal *auth.Principal, next http.HandlerFunc) {
+	ctx := auth.NewContext(r.Context(), principal)
+	if pin := r.Header.Get("X-Transaction-PIN"); pin != "" {
+		ctx = auth.WithTransactionPIN(ctx, pin)
+	}
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+type authenticationKey struct{}
 
-		// Token is valid, proceed with the request
-		next.ServeHTTP(w, r)
+type authentication struct {
+	principal *auth.Principal
+	status    int
+	message   string
+}
+
+// withAuthentication autentica a requisição e guarda o resultado no
+// contexto, para que a chave ou o token sejam validados uma vez só.
+func withAuthentication(r *http.Request) *http.Request {
+	principal, status, message := authenticate(r)
+	ctx := context.WithValue(r.Context(), authenticationKey{}, authentication{principal, status, message})
+	return r.WithContext(ctx)
+}
+
+// authenticate valida a chave do cabeçalho X-API-Key ou, sem ela, o token do
+// cabeçalho Authorization. Em caso de falha devolve o status e a mensagem da
+// resposta. Se withAuthentication já rodou, devolve o resultado guardado.
+func authenticate(r *http.Request) (*auth.Principal, int, string) {
+	if result, ok := r.Context().Value(authenticationKey{}).(authentication); ok {
+		return result.principal, result.status, result.message
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return authenticateAPIKey(r, key)
+	}
+
+	// Get the Authorization header
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, http.StatusUnauthorized, "Authorization header is required"
+	}
+
+	// Check if the header starts with "Bearer "
+	bearerToken := strings.Split(authHeader, " ")
+	if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
+		return nil, http.StatusUnauthorized, "Invalid authorization header format"
+	}
+
+	if jwtVerifier == nil {
+		return nil, http.StatusInternalServerError, "JWT verification is not configured"
+	}
+
+	// Parse and validate the token
+	claims, err := jwtVerifier.Parse(bearerToken[1])
+	if err != nil {
+		return nil, http.StatusUnauthorized, "Invalid token"
+	}
+
+	principal := auth.NewPrincipal(claims)
+	if principal.Subject == "" {
+		return nil, http.StatusUnauthorized, "Token without subject"
+	}
+	if principal.SessionID != "" && sessionValidator != nil {
+		if err := sessionValidator.ValidateSession(r.Context(), principal.SessionID, audit.IP(r.Context())); err != nil {
+			return nil, http.StatusUnauthorized, "Session revoked"
+		}
 	}
+	return principal, 0, ""
 }
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/red-velvet-workspace/banco-digital/internal/audit"
+	"github.com/red-velvet-workspace/banco-digital/internal/ratelimit"
+)
+
+// RateLimit limita as requisições por grupo de rotas com dois buckets: um
+// pelo IP de origem e outro pelo sub do token, quando há token válido.
+// Requisições acima do limite recebem 429 com Retry-After. O resultado da
+// autenticação segue no contexto para os middlewares das rotas.
+func RateLimit(store ratelimit.Store, cfg ratelimit.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, ok := cfg.Match(r.URL.Path)
+			if !ok || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			r = withAuthentication(r)
+			keys := []string{route.Group + "|ip|" + audit.IP(r.Context())}
+			if principal, _, _ := authenticate(r); principal != nil {
+				keys = append(keys, route.Group+"|sub|"+principal.Subject)
+			}
+
+			// Uma recusa não consome os outros buckets: quem passou do limite
+			// do sub não esgota o do IP, dividido com quem está no mesmo NAT
+			allowed, wait, err := store.Take(r.Context(), keys, route.Policy)
+			if err != nil {
+				// Falha no armazenamento não deve derrubar a API
+				log.Printf("[ERROR] Erro no rate limit para %v: %v", keys, err)
+				allowed = true
+			}
+
+			if !allowed {
+				log.Printf("[INFO] Rate limit excedido: %s %s (grupo %s)", r.Method, r.URL.Path, route.Group)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, "Muitas requisições, tente novamente mais tarde", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP devolve o IP de origem da requisição. Quando a conexão vem de um
+// proxy confiável, X-Forwarded-For é lido da direita para a esquerda e vale o
+// primeiro endereço que não é de um proxy confiável: os da esquerda foram
+// informados pelo próprio cliente e não servem para identificá-lo.
+func clientIP(r *http.Request, trustedProxy func(net.IP) bool) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !trustedProxy(ip) {
+		return host
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !trustedProxy(ip) {
+			break
+		}
+	}
+	return ip.String()
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/red-velvet-workspace/banco-digital/internal/auth"
+	"github.com/red-velvet-workspace/banco-digital/internal/ratelimit"
+)
+
+func TestRateLimitOverLimitSubjectKeepsIPBucket(t *testing.T) {
+	secret := []byte("segredo-de-teste-com-pelo-menos-32-bytes")
+	if err := InitJWT(JWTConfig{HMACSecret: secret, Issuer: "teste", Audience: "teste-api"}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { jwtVerifier = nil }()
+	issuer, err := auth.NewTokenIssuer(secret, "teste", "teste-api", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := issuer.Issue("cliente-abusivo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const burst = 3
+	cfg := ratelimit.Config{Routes: []ratelimit.RoutePolicy{
+		{Prefix: "/", Group: "default", Policy: ratelimit.Policy{Requests: 1, Per: time.Hour, Burst: burst}},
+	}}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := RequestContext(func(net.IP) bool { return false })(RateLimit(ratelimit.NewMemoryStore(), cfg)(ok))
+
+	request := func(ip string, withToken bool) int {
+		r := httptest.NewRequest(http.MethodGet, "/conta", nil)
+		r.RemoteAddr = ip + ":40000"
+		if withToken {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// O sub esgota o próprio bucket a partir de outra rede
+	for i := 0; i < burst; i++ {
+		if code := request("198.51.100.1", true); code != http.StatusOK {
+			t.Fatalf("requisição %d do sub: status %d", i, code)
+		}
+	}
+
+	// Atrás do NAT as requisições do sub são recusadas sem consumir o IP
+	for i := 0; i < 10; i++ {
+		if code := request("203.0.113.7", true); code != http.StatusTooManyRequests {
+			t.Fatalf("sub acima do limite: status %d, esperado 429", code)
+		}
+	}
+	for i := 0; i < burst; i++ {
+		if code := request("203.0.113.7", false); code != http.StatusOK {
+			t.Fatalf("requisição %d de outro cliente no mesmo IP: status %d", i, code)
+		}
+	}
+}
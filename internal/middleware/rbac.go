@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/red-velvet-workspace/banco-digital/internal/auth"
+)
+
+// RequirePermission valida o token como JWTMiddleware e só chama next se um
+// dos papéis do principal conceder a permissão declarada para a rota. Chaves
+// de API também são aceitas quando têm a permissão como escopo.
+func RequirePermission(permission auth.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, status, message := authenticate(r)
+		if principal == nil {
+			http.Error(w, message, status)
+			return
+		}
+		if !principal.Can(permission) {
+			http.Error(w, "Permissão insuficiente", http.StatusForbidden)
+			return
+		}
+		serveWithPrincipal(w, r, principal, next)
+	}
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/red-velvet-workspace/banco-digital/internal/audit"
+)
+
+// RequestContext identifica cada requisição pelo cabeçalho X-Request-ID, ou
+// por um ID novo quando ele não vem, e guarda o ID e o IP de origem no
+// contexto para o log de auditoria. trustedProxy diz se um IP é de um proxy
+// cujo X-Forwarded-For pode ser aceito.
+func RequestContext(trustedProxy func(net.IP) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get("X-Request-ID")
+			if requestID == "" || len(requestID) > 128 {
+				requestID = uuid.NewString()
+			}
+			w.Header().Set("X-Request-ID", requestID)
+
+			ctx := audit.WithRequest(r.Context(), requestID, clientIP(r, trustedProxy))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
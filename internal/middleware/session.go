@@ -0,0 +1,16 @@
+package middleware
+
+import "context"
+
+// SessionValidator confere a sessão da claim sid dos tokens emitidos no
+// login, recusando as que já foram encerradas.
+type SessionValidator interface {
+	ValidateSession(ctx context.Context, sessionID string, ip string) error
+}
+
+var sessionValidator SessionValidator
+
+// InitSessions habilita a verificação de sessões revogadas.
+func InitSessions(v SessionValidator) {
+	sessionValidator = v
+}
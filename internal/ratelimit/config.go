@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RoutePolicy aplica Policy às rotas que começam com Prefix. Rotas com o
+// mesmo Group dividem os buckets.
+type RoutePolicy struct {
+	Prefix string
+	Group  string
+	Policy Policy
+}
+
+// Config é a configuração do limitador. Store é "memory" ou "postgres";
+// TrustedProxies são as faixas dos proxies (o Kong) cujo X-Forwarded-For é
+// aceito para descobrir o IP de origem.
+type Config struct {
+	Store          string
+	TrustedProxies []*net.IPNet
+	Routes         []RoutePolicy
+}
+
+// DefaultRoutes protege as rotas mais sensíveis a abuso; o prefixo "/" é o
+// limite geral.
+var DefaultRoutes = []RoutePolicy{
+	{Prefix: "/auth/login", Group: "login", Policy: Policy{Requests: 10, Per: time.Minute, Burst: 5}},
+	{Prefix: "/auth/register", Group: "register", Policy: Policy{Requests: 5, Per: time.Hour, Burst: 3}},
+	{Prefix: "/conta/criar", Group: "account-creation", Policy: Policy{Requests: 5, Per: time.Hour, Burst: 2}},
+	{Prefix: "/pix/enviar", Group: "pix", Policy: Policy{Requests: 10, Per: time.Minute, Burst: 5}},
+	{Prefix: "/", Group: "default", Policy: Policy{Requests: 600, Per: time.Minute, Burst: 100}},
+}
+
+type routePolicyJSON struct {
+	Prefix   string `json:"prefix"`
+	Group    string `json:"group"`
+	Requests int    `json:"requests"`
+	Per      string `json:"per"`
+	Burst    int    `json:"burst"`
+}
+
+// LoadConfig lê RATE_LIMIT_STORE, RATE_LIMIT_TRUSTED_PROXIES e
+// RATE_LIMIT_POLICIES. RATE_LIMIT_TRUSTED_PROXIES é uma lista de IPs ou
+// faixas CIDR separados por vírgula. RATE_LIMIT_POLICIES é uma lista JSON de
+// {"prefix", "group", "requests", "per", "burst"} que substitui as políticas
+// padrão.
+func LoadConfig() (Config, error) {
+	cfg := Config{
+		Store:  strings.ToLower(os.Getenv("RATE_LIMIT_STORE")),
+		Routes: append([]RoutePolicy(nil), DefaultRoutes...),
+	}
+	if cfg.Store == "" {
+		cfg.Store = "memory"
+	}
+	if cfg.Store != "memory" && cfg.Store != "postgres" {
+		return cfg, fmt.Errorf("RATE_LIMIT_STORE inválido: %s", cfg.Store)
+	}
+
+	// Aceitar qualquer X-Forwarded-For deixaria o cliente escolher o próprio IP
+	if os.Getenv("RATE_LIMIT_TRUST_PROXY") == "true" {
+		return cfg, fmt.Errorf("RATE_LIMIT_TRUST_PROXY foi substituído por RATE_LIMIT_TRUSTED_PROXIES")
+	}
+	if v := os.Getenv("RATE_LIMIT_TRUSTED_PROXIES"); v != "" {
+		for _, entry := range strings.Split(v, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			network, err := parseNetwork(entry)
+			if err != nil {
+				return cfg, fmt.Errorf("RATE_LIMIT_TRUSTED_PROXIES inválido: %s", entry)
+			}
+			cfg.TrustedProxies = append(cfg.TrustedProxies, network)
+		}
+	}
+
+	if v := os.Getenv("RATE_LIMIT_POLICIES"); v != "" {
+		var raw []routePolicyJSON
+		if err := json.Unmarshal([]byte(v), &raw); err != nil {
+			return cfg, fmt.Errorf("RATE_LIMIT_POLICIES inválido: %v", err)
+		}
+		routes := make([]RoutePolicy, 0, len(raw))
+		for _, r := range raw {
+			per, err := time.ParseDuration(r.Per)
+			if err != nil || per <= 0 || r.Requests <= 0 || r.Burst <= 0 || r.Prefix == "" {
+				return cfg, fmt.Errorf("política de rate limit inválida para %q", r.Prefix)
+			}
+			group := r.Group
+			if group == "" {
+				group = r.Prefix
+			}
+			routes = append(routes, RoutePolicy{
+				Prefix: r.Prefix,
+				Group:  group,
+				Policy: Policy{Requests: r.Requests, Per: per, Burst: r.Burst},
+			})
+		}
+		cfg.Routes = routes
+	}
+
+	// O prefixo mais longo vence
+	sort.SliceStable(cfg.Routes, func(i, j int) bool {
+		return len(cfg.Routes[i].Prefix) > len(cfg.Routes[j].Prefix)
+	})
+	return cfg, nil
+}
+
+// TrustedProxy diz se ip pertence a um dos proxies confiáveis.
+func (c Config) TrustedProxy(ip net.IP) bool {
+	for _, network := range c.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNetwork aceita uma faixa CIDR ou um IP isolado.
+func parseNetwork(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, network, err := net.ParseCIDR(s)
+		return network, err
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("IP inválido: %s", s)
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// Match devolve a política da rota, ou false se nenhuma se aplica.
+func (c Config) Match(path string) (RoutePolicy, bool) {
+	for _, r := range c.Routes {
+		if strings.HasPrefix(path, r.Prefix) {
+			return r, true
+		}
+	}
+	return RoutePolicy{}, false
+}
@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
+	idleTTL time.Duration
+}
+
+// MemoryStore guarda os buckets no processo.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryBucket), lastSweep: time.Now()}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, keys []string, policy Policy) (bool, time.Duration, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		for k, b := range s.buckets {
+			if now.Sub(b.updated) > b.idleTTL {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	buckets := make([]*memoryBucket, len(keys))
+	lefts := make([]float64, len(keys))
+	allowed, wait := true, time.Duration(0)
+	for i, key := range keys {
+		b, ok := s.buckets[key]
+		if !ok {
+			b = &memoryBucket{tokens: float64(policy.Burst), updated: now, idleTTL: policy.fullAfter()}
+			s.buckets[key] = b
+		}
+		left, ok, retryAfter := policy.refill(b.tokens, b.updated, now)
+		if !ok {
+			allowed = false
+			if retryAfter > wait {
+				wait = retryAfter
+			}
+		}
+		buckets[i], lefts[i] = b, left
+	}
+	if !allowed {
+		return false, wait, nil
+	}
+
+	for i, b := range buckets {
+		b.tokens = lefts[i]
+		b.updated = now
+	}
+	return true, 0, nil
+}
@@ -0,0 +1,23 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTakeIsAllOrNothing(t *testing.T) {
+	store := NewMemoryStore()
+	policy := Policy{Requests: 1, Per: time.Hour, Burst: 1}
+
+	if ok, _, _ := store.Take(context.Background(), []string{"sub"}, policy); !ok {
+		t.Fatal("primeira ficha do sub recusada")
+	}
+	ok, retryAfter, _ := store.Take(context.Background(), []string{"ip", "sub"}, policy)
+	if ok || retryAfter <= 0 {
+		t.Fatalf("sub sem ficha aceito (ok=%v, retryAfter=%v)", ok, retryAfter)
+	}
+	if ok, _, _ := store.Take(context.Background(), []string{"ip"}, policy); !ok {
+		t.Error("a recusa do sub consumiu a ficha do IP")
+	}
+}
@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/red-velvet-workspace/banco-digital/internal/domain/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	postgresSweepInterval = 10 * time.Minute
+	postgresIdleTTL       = 24 * time.Hour
+)
+
+// PostgresStore guarda os buckets na tabela rate_limit_buckets, travando a
+// linha do bucket durante a atualização para que réplicas não consumam a
+// mesma ficha.
+type PostgresStore struct {
+	db *gorm.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db, lastSweep: time.Now()}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, keys []string, policy Policy) (bool, time.Duration, error) {
+	allowed := true
+	var retryAfter time.Duration
+
+	// Travar sempre na mesma ordem evita deadlock entre réplicas
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		lefts := make(map[string]float64, len(sorted))
+		for _, key := range sorted {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RateLimitBucket{
+				Key:       key,
+				Tokens:    float64(policy.Burst),
+				UpdatedAt: now,
+			}).Error; err != nil {
+				return err
+			}
+
+			var bucket models.RateLimitBucket
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				First(&bucket, "key = ?", key).Error; err != nil {
+				return err
+			}
+
+			left, ok, wait := policy.refill(bucket.Tokens, bucket.UpdatedAt, now)
+			if !ok {
+				allowed = false
+				if wait > retryAfter {
+					retryAfter = wait
+				}
+			}
+			lefts[key] = left
+		}
+		if !allowed {
+			return nil
+		}
+
+		for _, key := range sorted {
+			if err := tx.Model(&models.RateLimitBucket{}).Where("key = ?", key).
+				Updates(map[string]interface{}{"tokens": lefts[key], "updated_at": now}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, 0, err
+	}
+
+	// Buckets parados há muito tempo estão cheios e podem ser removidos
+	s.mu.Lock()
+	sweep := time.Since(s.lastSweep) >= postgresSweepInterval
+	if sweep {
+		s.lastSweep = time.Now()
+	}
+	s.mu.Unlock()
+	if sweep {
+		s.db.WithContext(ctx).Where("updated_at < ?", time.Now().Add(-postgresIdleTTL)).
+			Delete(&models.RateLimitBucket{})
+	}
+	return allowed, retryAfter, nil
+}
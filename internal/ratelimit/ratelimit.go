@@ -0,0 +1,49 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Policy define um token bucket: Requests fichas a cada Per, acumulando no
+// máximo Burst.
+type Policy struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+func (p Policy) rate() float64 {
+	return float64(p.Requests) / p.Per.Seconds()
+}
+
+// refill devolve as fichas do bucket em now e, se houver ao menos uma, a
+// consome. Quando a requisição é negada retryAfter diz quando a próxima
+// ficha estará disponível.
+func (p Policy) refill(tokens float64, last, now time.Time) (left float64, allowed bool, retryAfter time.Duration) {
+	if elapsed := now.Sub(last).Seconds(); elapsed > 0 {
+		tokens = math.Min(float64(p.Burst), tokens+elapsed*p.rate())
+	}
+	if tokens >= 1 {
+		return tokens - 1, true, 0
+	}
+	wait := (1 - tokens) / p.rate()
+	return tokens, false, time.Duration(math.Ceil(wait * float64(time.Second)))
+}
+
+// fullAfter é o tempo para um bucket vazio encher. Buckets parados por mais
+// que isso podem ser descartados sem mudar o resultado.
+func (p Policy) fullAfter() time.Duration {
+	return time.Duration(float64(p.Burst) / p.rate() * float64(time.Second))
+}
+
+// Store guarda os buckets. A implementação em memória serve para uma réplica;
+// a do Postgres compartilha os limites entre réplicas.
+//
+// Take consome uma ficha de cada bucket de keys só se todos tiverem ficha.
+// Quando algum recusa nenhum bucket é consumido, e retryAfter é a maior
+// espera entre os que recusaram.
+type Store interface {
+	Take(ctx context.Context, keys []string, policy Policy) (allowed bool, retryAfter time.Duration, err error)
+}
@@ -0,0 +1,288 @@
+package repositories
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/red-velvet-workspace/banco-digital/internal/domain/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type InvoiceRepository struct {
+	db *gorm.DB
+}
+
+func NewInvoiceRepository(db *gorm.DB) *InvoiceRepository {
+	return &InvoiceRepository{db: db}
+}
+
+// FindOrCreate devolve a fatura do cartão para o mês de referência,
+// criando-a aberta caso ainda não exista.
+func (r *InvoiceRepository) FindOrCreate(ctx context.Context, cardID uuid.UUID, referenceMonth string, closing, due time.Time) (*models.Invoice, error) {
+	invoice := models.Invoice{
+		ID:             uuid.New(),
+		CreditCardID:   cardID,
+		ReferenceMonth: referenceMonth,
+		ClosingDate:    closing,
+		DueDate:        due,
+		Status:         models.InvoiceOpen,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&invoice).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var existing models.Invoice
+	err = r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("credit_card_id = ? AND reference_month = ?", cardID, referenceMonth).
+		First(&existing).Error
+	return &existing, err
+}
+
+func (r *InvoiceRepository) AddAmount(ctx context.Context, invoiceID uuid.UUID, amount float64) error {
+	return r.db.WithContext(ctx).
+		Model(&models.Invoice{}).
+		Where("id = ?", invoiceID).
+		Updates(map[string]interface{}{
+			"total_amount": gorm.Expr("total_amount + ?", amount),
+			"updated_at":   time.Now(),
+		}).Error
+}
+
+func (r *InvoiceRepository) GetByCardID(ctx context.Context, cardID uuid.UUID) ([]models.Invoice, error) {
+	var invoices []models.Invoice
+	err := r.db.WithContext(ctx).
+		Where("credit_card_id = ?", cardID).
+		Order("reference_month").
+		Find(&invoices).Error
+	return invoices, err
+}
+
+func (r *InvoiceRepository) CreateInstallments(ctx context.Context, installments []models.Installment) error {
+	return r.db.WithContext(ctx).Create(&installments).Error
+}
+
+func (r *InvoiceRepository) GetPendingInstallments(ctx context.Context, transactionID uuid.UUID) ([]models.Installment, error) {
+	var installments []models.Installment
+	err := r.db.WithContext(ctx).
+		Where("transaction_id = ? AND status = ?", transactionID, models.InstallmentPending).
+		Order("number").
+		Find(&installments).Error
+	return installments, err
+}
+
+// CloseDueInvoices fecha as faturas abertas do cartão cuja data de
+// fechamento já passou.
+func (r *InvoiceRepository) CloseDueInvoices(ctx context.Context, cardID uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Model(&models.Invoice{}).
+		Where("credit_card_id = ? AND status = ? AND closing_date <= ?", cardID, models.InvoiceOpen, time.Now()).
+		Updates(map[string]interface{}{
+			"status": gorm.Expr("CASE WHEN paid_amount >= total_amount THEN ? ELSE ? END",
+				models.InvoicePaid, models.InvoiceClosed),
+			"updated_at": time.Now(),
+		}).Error
+}
+
+// ApplyPayment abate o valor das faturas do cartão e devolve quanto sobrou.
+// Com invoiceID o pagamento vai apenas para aquela fatura; sem ele, as
+// faturas fechadas são quitadas primeiro, por vencimento, e depois as
+// abertas.
+func (r *InvoiceRepository) ApplyPayment(ctx context.Context, cardID uuid.UUID, invoiceID *uuid.UUID, amount float64) (float64, error) {
+	if err := r.CloseDueInvoices(ctx, cardID); err != nil {
+		return 0, err
+	}
+
+	query := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("credit_card_id = ? AND status <> ? AND paid_amount < total_amount", cardID, models.InvoicePaid)
+	if invoiceID != nil {
+		query = query.Where("id = ?", *invoiceID)
+	}
+
+	var invoices []models.Invoice
+	err := query.
+		Order("CASE WHEN status = 'CLOSED' THEN 0 ELSE 1 END, due_date").
+		Find(&invoices).Error
+	if err != nil {
+		return 0, err
+	}
+
+	remaining := models.RoundCents(amount)
+	for _, invoice := range invoices {
+		if remaining <= 0 {
+			break
+		}
+		applied := math.Min(remaining, models.RoundCents(invoice.TotalAmount-invoice.PaidAmount))
+		if err := r.registerPayment(ctx, invoice.ID, applied); err != nil {
+			return 0, err
+		}
+		remaining = models.RoundCents(remaining - applied)
+	}
+	return remaining, nil
+}
+
+// ApplyCredit usa o saldo credor do cartão para quitar as faturas em
+// aberto. O limite já foi liberado quando o saldo credor foi formado.
+func (r *InvoiceRepository) ApplyCredit(ctx context.Context, cardID uuid.UUID) error {
+	var card models.CreditCard
+	err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&card, "id = ?", cardID).Error
+	if err != nil || card.CreditBalance <= 0 {
+		return err
+	}
+
+	remaining, err := r.ApplyPayment(ctx, cardID, nil, card.CreditBalance)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).
+		Model(&models.CreditCard{}).
+		Where("id = ?", cardID).
+		UpdateColumn("credit_balance", remaining).Error
+}
+
+// Prepay marca as parcelas pendentes da compra como antecipadas e as retira
+// das faturas, junto com a parte delas já paga. Devolve o valor em aberto
+// antecipado: a parte já paga teve o limite liberado por ApplyPayment.
+func (r *InvoiceRepository) Prepay(ctx context.Context, transactionID uuid.UUID) (float64, error) {
+	installments, err := r.GetPendingInstallments(ctx, transactionID)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	var outstanding float64
+	for _, installment := range installments {
+		paid, err := r.paidPart(ctx, installment)
+		if err != nil {
+			return 0, err
+		}
+		if err := r.db.WithContext(ctx).Model(&models.Installment{}).
+			Where("id = ?", installment.ID).
+			Updates(map[string]interface{}{
+				"status":     models.InstallmentPrepaid,
+				"paid_at":    now,
+				"updated_at": now,
+			}).Error; err != nil {
+			return 0, err
+		}
+		// A parcela sai inteira da fatura: o total perde o valor nominal e o
+		// pago perde o que já cobria a parcela
+		if err := r.db.WithContext(ctx).
+			Model(&models.Invoice{}).
+			Where("id = ?", installment.InvoiceID).
+			Updates(map[string]interface{}{
+				"total_amount": gorm.Expr("total_amount - ?", installment.Amount),
+				"paid_amount":  gorm.Expr("paid_amount - ?", paid),
+				"updated_at":   now,
+			}).Error; err != nil {
+			return 0, err
+		}
+		if err := r.db.WithContext(ctx).
+			Model(&models.Invoice{}).
+			Where("id = ? AND paid_amount >= total_amount AND status = ?", installment.InvoiceID, models.InvoiceClosed).
+			Update("status", models.InvoicePaid).Error; err != nil {
+			return 0, err
+		}
+		outstanding += installment.Amount - paid
+	}
+	return models.RoundCents(outstanding), nil
+}
+
+// OutstandingAmount devolve quanto da parcela ainda não foi pago.
+func (r *InvoiceRepository) OutstandingAmount(ctx context.Context, installment models.Installment) (float64, error) {
+	paid, err := r.paidPart(ctx, installment)
+	if err != nil {
+		return 0, err
+	}
+	return models.RoundCents(installment.Amount - paid), nil
+}
+
+// paidPart devolve quanto do valor pago da fatura cobre a parcela. Os
+// pagamentos cobrem as parcelas na mesma ordem usada por registerPayment.
+func (r *InvoiceRepository) paidPart(ctx context.Context, installment models.Installment) (float64, error) {
+	var invoice models.Invoice
+	if err := r.db.WithContext(ctx).First(&invoice, "id = ?", installment.InvoiceID).Error; err != nil {
+		return 0, err
+	}
+
+	var installments []models.Installment
+	if err := r.db.WithContext(ctx).
+		Where("invoice_id = ? AND status <> ?", installment.InvoiceID, models.InstallmentPrepaid).
+		Order("due_date, created_at, number").
+		Find(&installments).Error; err != nil {
+		return 0, err
+	}
+
+	covered := 0.0
+	for _, other := range installments {
+		if other.ID == installment.ID {
+			return models.RoundCents(math.Max(0, math.Min(installment.Amount, invoice.PaidAmount-covered))), nil
+		}
+		covered = models.RoundCents(covered + other.Amount)
+	}
+	return 0, nil
+}
+
+// registerPayment soma o valor pago à fatura, baixa as parcelas que o total
+// pago passa a cobrir e marca a fatura como paga quando fechada e quitada.
+func (r *InvoiceRepository) registerPayment(ctx context.Context, invoiceID uuid.UUID, amount float64) error {
+	if err := r.db.WithContext(ctx).
+		Model(&models.Invoice{}).
+		Where("id = ?", invoiceID).
+		Updates(map[string]interface{}{
+			"paid_amount": gorm.Expr("paid_amount + ?", amount),
+			"updated_at":  time.Now(),
+		}).Error; err != nil {
+		return err
+	}
+
+	var invoice models.Invoice
+	if err := r.db.WithContext(ctx).First(&invoice, "id = ?", invoiceID).Error; err != nil {
+		return err
+	}
+
+	var installments []models.Installment
+	if err := r.db.WithContext(ctx).
+		Where("invoice_id = ? AND status <> ?", invoiceID, models.InstallmentPrepaid).
+		Order("due_date, created_at, number").
+		Find(&installments).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	covered := 0.0
+	for _, installment := range installments {
+		covered = models.RoundCents(covered + installment.Amount)
+		if installment.Status != models.InstallmentPending {
+			continue
+		}
+		if covered > invoice.PaidAmount {
+			break
+		}
+		if err := r.db.WithContext(ctx).Model(&models.Installment{}).
+			Where("id = ?", installment.ID).
+			Updates(map[string]interface{}{
+				"status":     models.InstallmentPaid,
+				"paid_at":    now,
+				"updated_at": now,
+			}).Error; err != nil {
+			return err
+		}
+	}
+
+	return r.db.WithContext(ctx).
+		Model(&models.Invoice{}).
+		Where("id = ? AND paid_amount >= total_amount AND status = ?", invoiceID, models.InvoiceClosed).
+		Update("status", models.InvoicePaid).Error
+}
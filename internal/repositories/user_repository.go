@@ -0,0 +1,233 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/red-velvet-workspace/banco-digital/internal/domain/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UserRepository struct {
+	db *gorm.DB
+}
+
+func NewUserRepository(db *gorm.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, "email = ?", email).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// RegisterFailedLogin conta uma tentativa de login errada e bloqueia o
+// usuário até lockUntil quando as tentativas chegam a maxAttempts.
+func (r *UserRepository) RegisterFailedLogin(ctx context.Context, userID uuid.UUID, maxAttempts int, lockUntil time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{
+			"failed_logins": gorm.Expr("CASE WHEN failed_logins + 1 >= ? THEN 0 ELSE failed_logins + 1 END", maxAttempts),
+			"locked_until":  gorm.Expr("CASE WHEN failed_logins + 1 >= ? THEN ? ELSE locked_until END", maxAttempts, lockUntil),
+			"updated_at":    time.Now(),
+		}).Error
+}
+
+func (r *UserRepository) ResetFailedLogins(ctx context.Context, userID uuid.UUID) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{
+			"failed_logins": 0,
+			"locked_until":  nil,
+			"updated_at":    time.Now(),
+		}).Error
+}
+
+func (r *UserRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+// GetRefreshTokenForUpdate busca o refresh token pelo hash e trava a linha
+// para que duas renovações simultâneas não usem o mesmo token.
+func (r *UserRepository) GetRefreshTokenForUpdate(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&token, "token_hash = ?", tokenHash).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *UserRepository) RevokeRefreshToken(ctx context.Context, id uuid.UUID, replacedBy *uuid.UUID) error {
+	return r.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"revoked_at":  time.Now(),
+			"replaced_by": replacedBy,
+		}).Error
+}
+
+func (r *UserRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	return r.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *UserRepository) CreateSession(ctx context.Context, session *models.Session) error {
+	return r.db.WithContext(ctx).Create(session).Error
+}
+
+func (r *UserRepository) GetSession(ctx context.Context, id uuid.UUID) (*models.Session, error) {
+	var session models.Session
+	if err := r.db.WithContext(ctx).First(&session, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ListActiveSessions devolve as sessões não encerradas do usuário, da mais
+// recente para a mais antiga.
+func (r *UserRepository) ListActiveSessions(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *UserRepository) TouchSession(ctx context.Context, id uuid.UUID, ip string) error {
+	updates := map[string]interface{}{"last_seen_at": time.Now()}
+	if ip != "" {
+		updates["ip"] = ip
+	}
+	return r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(updates).Error
+}
+
+// RevokeSession encerra a sessão e revoga os refresh tokens da família dela.
+func (r *UserRepository) RevokeSession(ctx context.Context, id uuid.UUID) error {
+	if err := r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	return r.RevokeRefreshTokenFamily(ctx, id)
+}
+
+// ListRevokedSessionIDs devolve as sessões encerradas depois de since.
+func (r *UserRepository) ListRevokedSessionIDs(ctx context.Context, since time.Time) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("revoked_at > ?", since).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// GetPINForUpdate busca o PIN de transação do usuário e trava a linha para
+// que tentativas simultâneas não escapem da contagem de erros.
+func (r *UserRepository) GetPINForUpdate(ctx context.Context, userID uuid.UUID) (*models.TransactionPIN, error) {
+	var pin models.TransactionPIN
+	if err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&pin, "user_id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	return &pin, nil
+}
+
+func (r *UserRepository) SavePIN(ctx context.Context, pin *models.TransactionPIN) error {
+	return r.db.WithContext(ctx).Save(pin).Error
+}
+
+func (r *UserRepository) SetTOTP(ctx context.Context, userID uuid.UUID, secret string, enabled bool) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{
+			"totp_secret":    secret,
+			"totp_enabled":   enabled,
+			"totp_last_step": 0,
+			"updated_at":     time.Now(),
+		}).Error
+}
+
+// UseTOTPStep registra o passo TOTP aceito. Devolve false se o passo já foi
+// usado, o que indica um código reaproveitado.
+func (r *UserRepository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+// ReplaceRecoveryCodes apaga os códigos de recuperação do usuário e grava os
+// novos hashes.
+func (r *UserRepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, hashes []string) error {
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+	codes := make([]models.RecoveryCode, 0, len(hashes))
+	for _, hash := range hashes {
+		codes = append(codes, models.RecoveryCode{
+			ID:        uuid.New(),
+			UserID:    userID,
+			CodeHash:  hash,
+			CreatedAt: time.Now(),
+		})
+	}
+	return r.db.WithContext(ctx).Create(&codes).Error
+}
+
+// UseRecoveryCode marca o código como usado. Devolve false se ele não existe
+// ou já foi usado.
+func (r *UserRepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *UserRepository) IsKnownDevice(ctx context.Context, userID uuid.UUID, deviceHash string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.KnownDevice{}).
+		Where("user_id = ? AND device_hash = ?", userID, deviceHash).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// RememberDevice grava o dispositivo ou atualiza o último acesso dele.
+func (r *UserRepository) RememberDevice(ctx context.Context, device *models.KnownDevice) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "device_hash"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_agent", "last_ip", "last_seen_at"}),
+	}).Create(device).Error
+}
+
+// ForgetDevices esquece os dispositivos do usuário, exceto keepHash.
+func (r *UserRepository) ForgetDevices(ctx context.Context, userID uuid.UUID, keepHash string) error {
+	return r.db.WithContext(ctx).
+		Where("user_id = ? AND device_hash <> ?", userID, keepHash).
+		Delete(&models.KnownDevice{}).Error
+}
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/red-velvet-workspace/banco-digital/internal/audit"
+	"github.com/red-velvet-workspace/banco-digital/internal/auth"
 	"github.com/red-velvet-workspace/banco-digital/internal/domain/models"
 	"github.com/red-velvet-workspace/banco-digital/internal/infrastructure/kafka"
 	"github.com/red-velvet-workspace/banco-digital/internal/repositories"
@@ -15,14 +17,16 @@ import (
 type AccountService struct {
 	notificationRepo *repositories.NotificationRepository
 	db               *gorm.DB
-	producer         *kafka.Producer
+	producer         kafka.Publisher
+	pinPolicy        PINPolicy
 }
 
-func NewAccountService(db *gorm.DB, producer *kafka.Producer) (*AccountService, error) {
+func NewAccountService(db *gorm.DB, producer kafka.Publisher, pinPolicy PINPolicy) (*AccountService, error) {
 	return &AccountService{
 		notificationRepo: repositories.NewNotificationRepository(db),
 		db:               db,
 		producer:         producer,
+		pinPolicy:        pinPolicy,
 	}, nil
 }
 
@@ -43,11 +47,18 @@ func (s *AccountService) createNotification(ctx context.Context, accountID uuid.
 	return s.notificationRepo.Create(ctx, notification)
 }
 
+// CriarConta abre uma conta cujo dono é o autor da requisição.
 func (s *AccountService) CriarConta(ctx context.Context, accountType models.AccountType) (*models.APIResponse, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, ErrForbidden
+	}
+
 	account := &models.Account{
 		ID:        uuid.New(),
 		Type:      accountType,
 		Number:    fmt.Sprintf("%d", time.Now().UnixNano())[:10],
+		OwnerID:   principal.Subject,
 		Balance:   0,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
@@ -55,10 +66,12 @@ func (s *AccountService) CriarConta(ctx context.Context, accountType models.Acco
 
 	// Publica no Kafka
 	message := kafka.AccountMessage{
+		Event:     kafka.NewEvent(),
 		Operation: "CREATE",
 		Account:   *account,
+		Actor:     audit.ActorFromContext(ctx),
 	}
-	if err := s.producer.PublishMessage(kafka.TopicAccounts, message); err != nil {
+	if err := s.producer.PublishMessage(kafka.TopicAccounts, message.PartitionKey(), message); err != nil {
 		return &models.APIResponse{
 			Success: false,
 			Message: fmt.Sprintf("erro ao publicar criação da conta: %v", err),
@@ -83,26 +96,51 @@ func (s *AccountService) CriarConta(ctx context.Context, accountType models.Acco
 	}, nil
 }
 
+// AlterarStatus grava o novo status da conta junto com o registro de
+// auditoria, como UpdateAccountStatus.
 func (s *AccountService) AlterarStatus(ctx context.Context, accountID uuid.UUID, status string) (*models.APIResponse, error) {
-	err := s.createNotification(ctx, accountID, "STATUS_CHANGE",
-		fmt.Sprintf("O status da sua conta foi alterado para: %s", status))
-	if err != nil {
+	return s.UpdateAccountStatus(ctx, models.UpdateAccountStatusRequest{
+		AccountID: accountID,
+		Status:    status,
+	})
+}
+
+// ConfigurarChequeEspecial grava o limite de cheque especial da conta e o
+// registro de auditoria na mesma transação.
+func (s *AccountService) ConfigurarChequeEspecial(ctx context.Context, accountID uuid.UUID, limite float64) (*models.APIResponse, error) {
+	if err := exigirPermissao(ctx, auth.PermLimitsOverride); err != nil {
+		return nil, err
+	}
+	if limite < 0 {
 		return &models.APIResponse{
 			Success: false,
-			Message: fmt.Sprintf("Erro ao criar notificação de alteração de status: %v", err),
+			Message: "O limite de cheque especial não pode ser negativo",
 			Data:    nil,
 		}, nil
 	}
 
-	return &models.APIResponse{
-		Success: true,
-		Message: fmt.Sprintf("Status da conta alterado para: %s", status),
-		Data:    map[string]string{"status": status},
-	}, nil
-}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var account models.Account
+		if err := tx.First(&account, "id = ?", accountID).Error; err != nil {
+			return err
+		}
+		before := account
+		account.OverdraftLimit = limite
+		account.UpdatedAt = time.Now()
+		if err := tx.Model(&account).Select("overdraft_limit", "updated_at").Updates(&account).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActorFromContext(ctx), "account.overdraft_update", "account", account.ID.String(), before, account)
+	})
+	if err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("Erro ao configurar cheque especial: %v", err),
+			Data:    nil,
+		}, nil
+	}
 
-func (s *AccountService) ConfigurarChequeEspecial(ctx context.Context, accountID uuid.UUID, limite float64) (*models.APIResponse, error) {
-	err := s.createNotification(ctx, accountID, "OVERDRAFT_LIMIT",
+	err = s.createNotification(ctx, accountID, "OVERDRAFT_LIMIT",
 		fmt.Sprintf("Seu limite de cheque especial foi configurado para: R$ %.2f", limite))
 	if err != nil {
 		return &models.APIResponse{
@@ -122,6 +160,9 @@ func (s *AccountService) ConfigurarChequeEspecial(ctx context.Context, accountID
 }
 
 func (s *AccountService) ObterNotificacoes(ctx context.Context, accountID uuid.UUID) (*models.APIResponse, error) {
+	if err := s.autorizarConta(ctx, accountID); err != nil {
+		return nil, err
+	}
 	if err := s.notificationRepo.DeleteOldNotifications(ctx, accountID, "30 days"); err != nil {
 		return &models.APIResponse{
 			Success: false,
@@ -147,6 +188,9 @@ func (s *AccountService) ObterNotificacoes(ctx context.Context, accountID uuid.U
 }
 
 func (s *AccountService) RealizarTransacao(ctx context.Context, accountID uuid.UUID, tipo models.TransactionType, valor float64, descricao string, chaveDestino *string, cartaoID *uuid.UUID) (*models.APIResponse, error) {
+	if err := s.autorizarConta(ctx, accountID); err != nil {
+		return nil, err
+	}
 	// 1. Validar se a conta existe
 	var account models.Account
 	if err := s.db.WithContext(ctx).First(&account, "id = ?", accountID).Error; err != nil {
@@ -157,6 +201,21 @@ func (s *AccountService) RealizarTransacao(ctx context.Context, accountID uuid.U
 		}, nil
 	}
 
+	if account.Status == models.AccountFrozen {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Conta congelada",
+			Data:    nil,
+		}, nil
+	}
+
+	// Saques e PIX acima do limiar exigem o PIN de transação
+	if (tipo == models.Debit && valor > s.pinPolicy.Saque) || (tipo == models.PIXSent && valor > s.pinPolicy.PixEnvio) {
+		if err := s.verificarPIN(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	// 2. Validações de Saldo/Limite (Otimista)
 	switch tipo {
 	case models.Debit, models.PIXSent:
@@ -185,6 +244,13 @@ func (s *AccountService) RealizarTransacao(ctx context.Context, accountID uuid.U
 				Data:    nil,
 			}, nil
 		}
+		if card.AccountID != accountID {
+			return &models.APIResponse{
+				Success: false,
+				Message: "Cartão não pertence à conta informada",
+				Data:    nil,
+			}, nil
+		}
 		if card.AvailableLimit < valor {
 			return &models.APIResponse{
 				Success: false,
@@ -208,11 +274,13 @@ func (s *AccountService) RealizarTransacao(ctx context.Context, accountID uuid.U
 
 	// 4. Publicar no Kafka
 	message := kafka.TransactionMessage{
+		Event:       kafka.NewEvent(),
 		Operation:   "CREATE",
 		Transaction: transaction,
+		Actor:       audit.ActorFromContext(ctx),
 	}
 
-	if err := s.producer.PublishMessage(kafka.TopicTransactions, message); err != nil {
+	if err := s.producer.PublishMessage(kafka.TopicTransactions, message.PartitionKey(), message); err != nil {
 		return &models.APIResponse{
 			Success: false,
 			Message: fmt.Sprintf("erro ao processar transação: %v", err),
@@ -250,11 +318,15 @@ func (s *AccountService) RealizarTransacao(ctx context.Context, accountID uuid.U
 }
 
 func (s *AccountService) CriarCartao(ctx context.Context, accountID uuid.UUID, limite float64) (*models.APIResponse, error) {
+	if err := s.autorizarConta(ctx, accountID); err != nil {
+		return nil, err
+	}
 	card := &models.CreditCard{
 		ID:             uuid.New(),
 		AccountID:      accountID,
 		AvailableLimit: limite,
 		CreditLimit:    limite * 0.7, // 70% of total limit
+		Status:         models.CardActive,
 		Number:         fmt.Sprintf("4532-%s", uuid.New().String()[:12]),
 		DueDate:        10, // default to 10th
 		CreatedAt:      time.Now(),
@@ -263,10 +335,12 @@ func (s *AccountService) CriarCartao(ctx context.Context, accountID uuid.UUID, l
 
 	// Publish to Kafka
 	message := kafka.CreditCardMessage{
+		Event:      kafka.NewEvent(),
 		Operation:  "CREATE",
 		CreditCard: *card,
+		Actor:      audit.ActorFromContext(ctx),
 	}
-	if err := s.producer.PublishMessage(kafka.TopicCreditCards, message); err != nil {
+	if err := s.producer.PublishMessage(kafka.TopicCreditCards, message.PartitionKey(), message); err != nil {
 		return &models.APIResponse{
 			Success: false,
 			Message: fmt.Sprintf("erro ao publicar criação do cartão: %v", err),
@@ -291,7 +365,33 @@ func (s *AccountService) CriarCartao(ctx context.Context, accountID uuid.UUID, l
 }
 
 func (s *AccountService) AlterarStatusCartao(ctx context.Context, cardID uuid.UUID, status string) (*models.APIResponse, error) {
-	err := s.createNotification(ctx, cardID, "CARD_STATUS_CHANGE",
+	card, err := s.autorizarCartao(ctx, cardID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Relido na transação para que o "antes" não perca compras recentes
+		if err := tx.First(card, "id = ?", cardID).Error; err != nil {
+			return err
+		}
+		before := *card
+		card.Status = status
+		card.UpdatedAt = time.Now()
+		if err := tx.Model(card).Select("status", "updated_at").Updates(card).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActorFromContext(ctx), "credit_card.status_update", "credit_card", card.ID.String(), before, *card)
+	})
+	if err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("Erro ao alterar status do cartão: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	err = s.createNotification(ctx, card.AccountID, "CARD_STATUS_CHANGE",
 		fmt.Sprintf("O status do seu cartão foi alterado para: %s", status))
 	if err != nil {
 		return &models.APIResponse{
@@ -310,8 +410,46 @@ func (s *AccountService) AlterarStatusCartao(ctx context.Context, cardID uuid.UU
 	}, nil
 }
 
+// AlterarLimiteCartao redefine o limite do cartão. O limite disponível muda
+// pela mesma diferença, preservando o que já está comprometido.
 func (s *AccountService) AlterarLimiteCartao(ctx context.Context, cardID uuid.UUID, limite float64) (*models.APIResponse, error) {
-	err := s.createNotification(ctx, cardID, "CARD_LIMIT_CHANGE",
+	if err := exigirPermissao(ctx, auth.PermLimitsOverride); err != nil {
+		return nil, err
+	}
+
+	var card models.CreditCard
+	if err := s.db.WithContext(ctx).First(&card, "id = ?", cardID).Error; err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Cartão não encontrado",
+			Data:    nil,
+		}, nil
+	}
+
+	// Um token roubado da equipe não basta para aumentar limites
+	principal, _ := auth.FromContext(ctx)
+	if s.pinPolicy.exigePINAumentoLimite(principal, card.CreditLimit, limite) {
+		if err := s.verificarPIN(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	card.CreditLimit = limite
+	message := kafka.CreditCardMessage{
+		Event:      kafka.NewEvent(),
+		Operation:  "UPDATE_LIMIT",
+		CreditCard: card,
+		Actor:      audit.ActorFromContext(ctx),
+	}
+	if err := s.producer.PublishMessage(kafka.TopicCreditCards, message.PartitionKey(), message); err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao publicar alteração de limite: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	err := s.createNotification(ctx, card.AccountID, "CARD_LIMIT_CHANGE",
 		fmt.Sprintf("O limite do seu cartão foi alterado para: R$ %.2f", limite))
 	if err != nil {
 		return &models.APIResponse{
@@ -330,15 +468,64 @@ func (s *AccountService) AlterarLimiteCartao(ctx context.Context, cardID uuid.UU
 	}, nil
 }
 
-func (s *AccountService) GerarCartaoVirtual(ctx context.Context, cardID uuid.UUID) (*models.APIResponse, error) {
+func (s *AccountService) GerarCartaoVirtual(ctx context.Context, cardID uuid.UUID, usoUnico bool, limite *float64) (*models.APIResponse, error) {
+	parent, err := s.autorizarCartao(ctx, cardID)
+	if err != nil {
+		return nil, err
+	}
+
+	if limite != nil && *limite <= 0 {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Limite do cartão virtual deve ser positivo",
+			Data:    nil,
+		}, nil
+	}
+
+	number, err := generateCardNumber(virtualCardBIN)
+	if err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao gerar número do cartão virtual: %v", err),
+			Data:    nil,
+		}, nil
+	}
+	cvv, err := randomDigits(3)
+	if err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao gerar CVV do cartão virtual: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
 	card := &models.VirtualCard{
-		ID:           uuid.New(),
-		CreditCardID: cardID,
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
+		ID:             uuid.New(),
+		CreditCardID:   cardID,
+		Number:         number,
+		ExpirationDate: time.Now().AddDate(virtualCardValidityYears, 0, 0),
+		CVV:            cvv,
+		SingleUse:      usoUnico,
+		SpendingLimit:  limite,
+		Status:         models.VirtualCardActive,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
 	}
 
-	if err := s.createNotification(ctx, cardID, "VIRTUAL_CARD_CREATED",
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(card).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActorFromContext(ctx), "virtual_card.create", "virtual_card", card.ID.String(), nil, card)
+	}); err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao salvar cartão virtual: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	if err := s.createNotification(ctx, parent.AccountID, "VIRTUAL_CARD_CREATED",
 		"Cartão virtual criado com sucesso"); err != nil {
 		return &models.APIResponse{
 			Success: false,
@@ -350,11 +537,81 @@ func (s *AccountService) GerarCartaoVirtual(ctx context.Context, cardID uuid.UUI
 	return &models.APIResponse{
 		Success: true,
 		Message: "Cartão virtual criado com sucesso",
-		Data:    card,
+		Data:    models.VirtualCardResponse{VirtualCard: *card, CVV: card.CVV},
+	}, nil
+}
+
+func (s *AccountService) ListarCartoesVirtuais(ctx context.Context, cardID uuid.UUID) (*models.APIResponse, error) {
+	if _, err := s.autorizarCartao(ctx, cardID); err != nil {
+		return nil, err
+	}
+	var cards []models.VirtualCard
+	if err := s.db.WithContext(ctx).
+		Where("credit_card_id = ? AND status <> ?", cardID, models.VirtualCardDeleted).
+		Order("created_at DESC").
+		Find(&cards).Error; err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao obter cartões virtuais: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: "Cartões virtuais obtidos com sucesso",
+		Data:    cards,
+	}, nil
+}
+
+// ExcluirCartaoVirtual desativa o cartão virtual. O registro é mantido para
+// que as compras feitas com ele continuem rastreáveis.
+func (s *AccountService) ExcluirCartaoVirtual(ctx context.Context, virtualCardID uuid.UUID) (*models.APIResponse, error) {
+	if err := s.autorizarCartaoVirtual(ctx, virtualCardID); err != nil {
+		return nil, err
+	}
+	var card models.VirtualCard
+	if err := s.db.WithContext(ctx).
+		First(&card, "id = ? AND status <> ?", virtualCardID, models.VirtualCardDeleted).Error; err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Cartão virtual não encontrado",
+			Data:    nil,
+		}, nil
+	}
+
+	before := card
+	card.Status = models.VirtualCardDeleted
+	card.UpdatedAt = time.Now()
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&card).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActorFromContext(ctx), "virtual_card.delete", "virtual_card", card.ID.String(), before, card)
+	}); err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao excluir cartão virtual: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: "Cartão virtual excluído com sucesso",
+		Data:    map[string]interface{}{"virtual_card_id": virtualCardID},
 	}, nil
 }
 
 func (s *AccountService) RegistrarChavePix(ctx context.Context, accountID uuid.UUID, keyType string, key string) (*models.APIResponse, error) {
+	if err := s.autorizarConta(ctx, accountID); err != nil {
+		return nil, err
+	}
+	if s.pinPolicy.ChavePix {
+		if err := s.verificarPIN(ctx); err != nil {
+			return nil, err
+		}
+	}
 	pixKey := models.PIXKey{
 		ID:        uuid.New(),
 		AccountID: accountID,
@@ -366,10 +623,12 @@ func (s *AccountService) RegistrarChavePix(ctx context.Context, accountID uuid.U
 
 	// Publish to Kafka
 	message := kafka.PIXKeyMessage{
+		Event:     kafka.NewEvent(),
 		Operation: "CREATE",
 		PIXKey:    pixKey,
+		Actor:     audit.ActorFromContext(ctx),
 	}
-	if err := s.producer.PublishMessage(kafka.TopicPIX, message); err != nil {
+	if err := s.producer.PublishMessage(kafka.TopicPIX, message.PartitionKey(), message); err != nil {
 		return &models.APIResponse{
 			Success: false,
 			Message: fmt.Sprintf("erro ao publicar registro de chave PIX: %v", err),
@@ -394,6 +653,9 @@ func (s *AccountService) RegistrarChavePix(ctx context.Context, accountID uuid.U
 }
 
 func (s *AccountService) GerarQRCodePix(ctx context.Context, accountID uuid.UUID, valor float64, descricao string) (*models.APIResponse, error) {
+	if err := s.autorizarConta(ctx, accountID); err != nil {
+		return nil, err
+	}
 	qrCode := &models.PIXQRCode{
 		ID:          uuid.New(),
 		AccountID:   accountID,
@@ -418,8 +680,12 @@ func (s *AccountService) GerarQRCodePix(ctx context.Context, accountID uuid.UUID
 	}, nil
 }
 
-func (s *AccountService) CancelarAgendamentoPix(ctx context.Context, pixID uuid.UUID) (*models.APIResponse, error) {
-	if err := s.createNotification(ctx, pixID, "PIX_SCHEDULING_CANCELLED",
+func (s *AccountService) CancelarAgendamentoPix(ctx context.Context, accountID uuid.UUID, pixID uuid.UUID) (*models.APIResponse, error) {
+	if err := s.autorizarConta(ctx, accountID); err != nil {
+		return nil, err
+	}
+
+	if err := s.createNotification(ctx, accountID, "PIX_SCHEDULING_CANCELLED",
 		"Agendamento PIX cancelado com sucesso"); err != nil {
 		return &models.APIResponse{
 			Success: false,
@@ -436,6 +702,9 @@ func (s *AccountService) CancelarAgendamentoPix(ctx context.Context, pixID uuid.
 }
 
 func (s *AccountService) UpdateAccountStatus(ctx context.Context, req models.UpdateAccountStatusRequest) (*models.APIResponse, error) {
+	if err := exigirPermissao(ctx, auth.PermAccountsManage); err != nil {
+		return nil, err
+	}
 	var account models.Account
 	if err := s.db.WithContext(ctx).First(&account, "id = ?", req.AccountID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -452,10 +721,17 @@ func (s *AccountService) UpdateAccountStatus(ctx context.Context, req models.Upd
 		}, nil
 	}
 
+	before := account
 	account.Status = req.Status
 	account.UpdatedAt = time.Now()
 
-	if err := s.db.WithContext(ctx).Save(&account).Error; err != nil {
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Só o status: o saldo é do consumidor e pode ter mudado desde a leitura
+		if err := tx.Model(&account).Select("status", "updated_at").Updates(&account).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActorFromContext(ctx), "account.status_update", "account", account.ID.String(), before, account)
+	}); err != nil {
 		return &models.APIResponse{
 			Success: false,
 			Message: fmt.Sprintf("Failed to update account status: %v", err),
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/red-velvet-workspace/banco-digital/internal/audit"
+	"github.com/red-velvet-workspace/banco-digital/internal/auth"
+	"github.com/red-velvet-workspace/banco-digital/internal/domain/models"
+)
+
+const maxCustomerSearchResults = 50
+
+// CongelarConta bloqueia ou libera as movimentações de uma conta.
+func (s *AccountService) CongelarConta(ctx context.Context, accountID uuid.UUID, congelar bool) (*models.APIResponse, error) {
+	if err := exigirPermissao(ctx, auth.PermAccountsManage); err != nil {
+		return nil, err
+	}
+
+	status := "ACTIVE"
+	if congelar {
+		status = models.AccountFrozen
+	}
+	return s.UpdateAccountStatus(ctx, models.UpdateAccountStatusRequest{
+		AccountID: accountID,
+		Status:    status,
+	})
+}
+
+// BuscarClientes procura clientes por nome, e-mail ou número de conta.
+func (s *AccountService) BuscarClientes(ctx context.Context, termo string) (*models.APIResponse, error) {
+	if err := exigirPermissao(ctx, auth.PermCustomersSearch); err != nil {
+		return nil, err
+	}
+
+	termo = strings.TrimSpace(termo)
+	if len(termo) < 3 {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Informe ao menos 3 caracteres para a busca",
+			Data:    nil,
+		}, nil
+	}
+
+	like := "%" + strings.ToLower(termo) + "%"
+	var users []models.User
+	if err := s.db.WithContext(ctx).
+		Where("LOWER(email) LIKE ? OR LOWER(name) LIKE ?", like, like).
+		Or("id::text IN (?)", s.db.Model(&models.Account{}).Select("owner_id").Where("number = ?", termo)).
+		Order("name").
+		Limit(maxCustomerSearchResults).
+		Find(&users).Error; err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao buscar clientes: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	ownerIDs := make([]string, 0, len(users))
+	for _, user := range users {
+		ownerIDs = append(ownerIDs, user.ID.String())
+	}
+	var accounts []models.Account
+	if len(ownerIDs) > 0 {
+		if err := s.db.WithContext(ctx).Where("owner_id IN ?", ownerIDs).Find(&accounts).Error; err != nil {
+			return &models.APIResponse{
+				Success: false,
+				Message: fmt.Sprintf("erro ao buscar contas dos clientes: %v", err),
+				Data:    nil,
+			}, nil
+		}
+	}
+
+	byOwner := make(map[string][]models.Account, len(users))
+	for _, account := range accounts {
+		byOwner[account.OwnerID] = append(byOwner[account.OwnerID], account)
+	}
+	customers := make([]models.CustomerSummary, 0, len(users))
+	for _, user := range users {
+		customers = append(customers, models.CustomerSummary{
+			User:     user,
+			Accounts: byOwner[user.ID.String()],
+		})
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d cliente(s) encontrado(s)", len(customers)),
+		Data:    customers,
+	}, nil
+}
+
+// ConsultarAuditoria lista os registros do log de auditoria que atendem ao
+// filtro, do mais recente para o mais antigo.
+func (s *AccountService) ConsultarAuditoria(ctx context.Context, filtro audit.Filter) (*models.APIResponse, error) {
+	if err := exigirPermissao(ctx, auth.PermAuditRead); err != nil {
+		return nil, err
+	}
+
+	entries, err := audit.Query(ctx, s.db, filtro)
+	if err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao consultar auditoria: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d registro(s) encontrado(s)", len(entries)),
+		Data:    entries,
+	}, nil
+}
+
+// VerificarAuditoria recalcula a cadeia de hashes do log de auditoria e
+// aponta o primeiro registro adulterado, se houver.
+func (s *AccountService) VerificarAuditoria(ctx context.Context) (*models.APIResponse, error) {
+	if err := exigirPermissao(ctx, auth.PermAuditRead); err != nil {
+		return nil, err
+	}
+
+	brokenID, err := audit.Verify(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+	if brokenID != 0 {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("Cadeia de auditoria adulterada a partir do registro %d", brokenID),
+			Data:    map[string]uint64{"registro_adulterado": brokenID},
+		}, nil
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: "Cadeia de auditoria íntegra",
+		Data:    nil,
+	}, nil
+}
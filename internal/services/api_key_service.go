@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/red-velvet-workspace/banco-digital/internal/audit"
+	"github.com/red-velvet-workspace/banco-digital/internal/auth"
+	"github.com/red-velvet-workspace/banco-digital/internal/domain/models"
+	"github.com/red-velvet-workspace/banco-digital/internal/repositories"
+	"gorm.io/gorm"
+)
+
+const (
+	apiKeyPrefix = "bdk_"
+	// Intervalo mínimo entre atualizações de LastUsedAt de uma chave
+	apiKeyTouchInterval = time.Minute
+)
+
+var errAPIKeyIPNotAllowed = errors.New("IP de origem não autorizado para a chave")
+
+type APIKeyService struct {
+	db   *gorm.DB
+	keys *repositories.APIKeyRepository
+}
+
+func NewAPIKeyService(db *gorm.DB) (*APIKeyService, error) {
+	return &APIKeyService{
+		db:   db,
+		keys: repositories.NewAPIKeyRepository(db),
+	}, nil
+}
+
+// CriarChave gera uma chave de API. A chave em texto puro só aparece nesta
+// resposta.
+func (s *APIKeyService) CriarChave(ctx context.Context, req models.CreateAPIKeyRequest) (*models.APIResponse, error) {
+	if err := exigirPermissao(ctx, auth.PermAPIKeysManage); err != nil {
+		return nil, err
+	}
+
+	if msg := validarChaveAPI(req); msg != "" {
+		return &models.APIResponse{
+			Success: false,
+			Message: msg,
+			Data:    nil,
+		}, nil
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	plaintext := apiKeyPrefix + secret
+
+	actor := audit.ActorFromContext(ctx)
+	key := &models.APIKey{
+		ID:         uuid.New(),
+		Name:       strings.TrimSpace(req.Nome),
+		Prefix:     plaintext[:len(apiKeyPrefix)+8],
+		KeyHash:    hashToken(plaintext),
+		Scopes:     req.Escopos,
+		Accounts:   req.Contas,
+		AllowedIPs: req.IPs,
+		CreatedBy:  actor.ID,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := repositories.NewAPIKeyRepository(tx).Create(ctx, key); err != nil {
+			return err
+		}
+		return audit.Record(tx, actor, "api_key.create", "api_key", key.ID.String(), nil, key)
+	})
+	if err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao criar chave de API: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: "Chave de API criada. Guarde-a, ela não será exibida de novo",
+		Data:    models.CreatedAPIKey{APIKey: *key, Key: plaintext},
+	}, nil
+}
+
+func (s *APIKeyService) ListarChaves(ctx context.Context) (*models.APIResponse, error) {
+	if err := exigirPermissao(ctx, auth.PermAPIKeysManage); err != nil {
+		return nil, err
+	}
+
+	keys, err := s.keys.List(ctx)
+	if err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao listar chaves de API: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d chave(s) encontrada(s)", len(keys)),
+		Data:    keys,
+	}, nil
+}
+
+// RevogarChave desativa a chave imediatamente.
+func (s *APIKeyService) RevogarChave(ctx context.Context, keyID uuid.UUID) (*models.APIResponse, error) {
+	if err := exigirPermissao(ctx, auth.PermAPIKeysManage); err != nil {
+		return nil, err
+	}
+
+	before, err := s.keys.GetByID(ctx, keyID)
+	if err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Chave de API não encontrada",
+			Data:    nil,
+		}, nil
+	}
+	if before.RevokedAt != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Chave de API já revogada",
+			Data:    nil,
+		}, nil
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		keys := repositories.NewAPIKeyRepository(tx)
+		if err := keys.Revoke(ctx, keyID); err != nil {
+			return err
+		}
+		after, err := keys.GetByID(ctx, keyID)
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActorFromContext(ctx), "api_key.revoke", "api_key", keyID.String(), before, after)
+	})
+	if err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao revogar chave de API: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: "Chave de API revogada",
+		Data:    nil,
+	}, nil
+}
+
+// Authenticate valida a chave recebida no cabeçalho X-API-Key e monta o
+// principal com os escopos e contas da chave.
+func (s *APIKeyService) Authenticate(ctx context.Context, key string, ip string) (*auth.Principal, error) {
+	stored, err := s.keys.GetActiveByHash(ctx, hashToken(key))
+	if err != nil {
+		return nil, err
+	}
+	if !ipPermitido(stored.AllowedIPs, ip) {
+		return nil, errAPIKeyIPNotAllowed
+	}
+
+	if stored.LastUsedAt == nil || time.Since(*stored.LastUsedAt) > apiKeyTouchInterval {
+		if err := s.keys.TouchLastUsed(ctx, stored.ID); err != nil {
+			log.Printf("[ERROR] Erro ao registrar uso da chave de API %s: %v", stored.ID, err)
+		}
+	}
+
+	scopes := make([]auth.Permission, 0, len(stored.Scopes))
+	for _, scope := range stored.Scopes {
+		scopes = append(scopes, auth.Permission(scope))
+	}
+	accounts := make([]uuid.UUID, 0, len(stored.Accounts))
+	for _, account := range stored.Accounts {
+		if id, err := uuid.Parse(account); err == nil {
+			accounts = append(accounts, id)
+		}
+	}
+	return auth.NewAPIKeyPrincipal(stored.ID, scopes, accounts), nil
+}
+
+func validarChaveAPI(req models.CreateAPIKeyRequest) string {
+	if strings.TrimSpace(req.Nome) == "" {
+		return "Nome da chave é obrigatório"
+	}
+	if len(req.Escopos) == 0 {
+		return "Informe ao menos um escopo"
+	}
+	for _, scope := range req.Escopos {
+		if !escopoValido(scope) {
+			return fmt.Sprintf("Escopo inválido: %s", scope)
+		}
+	}
+	for _, account := range req.Contas {
+		if _, err := uuid.Parse(account); err != nil {
+			return fmt.Sprintf("ID de conta inválido: %s", account)
+		}
+	}
+	for _, ip := range req.IPs {
+		if net.ParseIP(ip) == nil {
+			if _, _, err := net.ParseCIDR(ip); err != nil {
+				return fmt.Sprintf("IP ou faixa CIDR inválida: %s", ip)
+			}
+		}
+	}
+	return ""
+}
+
+func escopoValido(scope string) bool {
+	for _, allowed := range auth.APIKeyScopes {
+		if string(allowed) == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ipPermitido confere o IP de origem com a lista da chave. Lista vazia
+// libera qualquer IP.
+func ipPermitido(allowed []string, ip string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, entry := range allowed {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+			continue
+		}
+		if allowedIP := net.ParseIP(entry); allowedIP != nil && allowedIP.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
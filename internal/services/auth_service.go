@@ -0,0 +1,420 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/red-velvet-workspace/banco-digital/internal/audit"
+	"github.com/red-velvet-workspace/banco-digital/internal/auth"
+	"github.com/red-velvet-workspace/banco-digital/internal/domain/models"
+	"github.com/red-velvet-workspace/banco-digital/internal/repositories"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72 // limite do bcrypt
+
+	maxFailedLogins = 5
+	lockoutDuration = 15 * time.Minute
+)
+
+var (
+	// ErrInvalidCredentials é devolvido para e-mail, senha ou refresh token
+	// inválidos, sem distinguir qual deles falhou.
+	ErrInvalidCredentials = errors.New("credenciais inválidas")
+	// ErrUserLocked é devolvido enquanto o usuário está bloqueado por
+	// excesso de tentativas de login.
+	ErrUserLocked = errors.New("usuário bloqueado temporariamente por excesso de tentativas")
+)
+
+type AuthService struct {
+	db         *gorm.DB
+	users      *repositories.UserRepository
+	issuer     *auth.TokenIssuer
+	refreshTTL time.Duration
+	totpBox    *auth.SecretBox
+	sessions   *SessionService
+	dummyHash  []byte
+}
+
+func NewAuthService(db *gorm.DB, issuer *auth.TokenIssuer, refreshTTL time.Duration, totpBox *auth.SecretBox, sessions *SessionService) (*AuthService, error) {
+	// Comparar com um hash qualquer quando o e-mail não existe mantém o tempo
+	// de resposta igual ao de uma senha errada
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte("senha-de-referencia"), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthService{
+		db:         db,
+		users:      repositories.NewUserRepository(db),
+		issuer:     issuer,
+		refreshTTL: refreshTTL,
+		totpBox:    totpBox,
+		sessions:   sessions,
+		dummyHash:  dummyHash,
+	}, nil
+}
+
+func (s *AuthService) Registrar(ctx context.Context, nome string, email string, senha string) (*models.APIResponse, error) {
+	email = normalizarEmail(email)
+	if _, err := mail.ParseAddress(email); err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: "E-mail inválido",
+			Data:    nil,
+		}, nil
+	}
+	if len(senha) < minPasswordLength || len(senha) > maxPasswordLength {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("A senha deve ter entre %d e %d caracteres", minPasswordLength, maxPasswordLength),
+			Data:    nil,
+		}, nil
+	}
+
+	if _, err := s.users.GetByEmail(ctx, email); err == nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: "E-mail já cadastrado",
+			Data:    nil,
+		}, nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(senha), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		ID:           uuid.New(),
+		Email:        email,
+		Name:         strings.TrimSpace(nome),
+		Role:         string(auth.RoleCustomer),
+		PasswordHash: string(hash),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := repositories.NewUserRepository(tx).Create(ctx, user); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActorFromContext(ctx), "user.register", "user", user.ID.String(), nil, user)
+	}); err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao cadastrar usuário: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: "Usuário cadastrado com sucesso",
+		Data:    user,
+	}, nil
+}
+
+// Login confere a senha e emite um par de tokens. Depois de maxFailedLogins
+// tentativas erradas o usuário fica bloqueado por lockoutDuration. Com o TOTP
+// ativado, logins de dispositivos desconhecidos exigem também o código.
+func (s *AuthService) Login(ctx context.Context, email string, senha string, codigo string, dispositivo Dispositivo) (*models.APIResponse, error) {
+	user, err := s.users.GetByEmail(ctx, normalizarEmail(email))
+	if err != nil {
+		_ = bcrypt.CompareHashAndPassword(s.dummyHash, []byte(senha))
+		return nil, ErrInvalidCredentials
+	}
+
+	if usuarioBloqueado(user, time.Now()) {
+		return nil, ErrUserLocked
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(senha)); err != nil {
+		if err := s.users.RegisterFailedLogin(ctx, user.ID, maxFailedLogins, time.Now().Add(lockoutDuration)); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.TOTPEnabled {
+		known := false
+		if deviceHash := dispositivo.hash(); deviceHash != "" {
+			if known, err = s.users.IsKnownDevice(ctx, user.ID, deviceHash); err != nil {
+				return nil, err
+			}
+		}
+		if !known {
+			if err := s.verificarSegundoFator(ctx, user, codigo); err != nil {
+				if errors.Is(err, ErrInvalidCredentials) {
+					if err := s.users.RegisterFailedLogin(ctx, user.ID, maxFailedLogins, time.Now().Add(lockoutDuration)); err != nil {
+						return nil, err
+					}
+				}
+				return nil, err
+			}
+		}
+	}
+
+	if user.FailedLogins > 0 || user.LockedUntil != nil {
+		if err := s.users.ResetFailedLogins(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if deviceHash := dispositivo.hash(); deviceHash != "" {
+		err := s.users.RememberDevice(ctx, &models.KnownDevice{
+			ID:         uuid.New(),
+			UserID:     user.ID,
+			DeviceHash: deviceHash,
+			UserAgent:  dispositivo.UserAgent,
+			LastIP:     audit.ActorFromContext(ctx).IP,
+			CreatedAt:  time.Now(),
+			LastSeenAt: time.Now(),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	session := &models.Session{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		DeviceName: dispositivo.Nome,
+		UserAgent:  dispositivo.UserAgent,
+		IP:         audit.IP(ctx),
+		CreatedAt:  time.Now(),
+		LastSeenAt: time.Now(),
+	}
+	if err := s.users.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+
+	tokens, _, err := s.emitirTokens(ctx, s.users, user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: "Login realizado com sucesso",
+		Data:    tokens,
+	}, nil
+}
+
+// usuarioBloqueado diz se o usuário está no bloqueio por excesso de
+// tentativas.
+func usuarioBloqueado(user *models.User, agora time.Time) bool {
+	return user.LockedUntil != nil && agora.Before(*user.LockedUntil)
+}
+
+// RenovarToken troca um refresh token válido por um novo par. O token usado
+// é revogado; se ele já tinha sido revogado, alguém o reutilizou e a sessão
+// inteira é encerrada. Usuários bloqueados não renovam, como no Login.
+func (s *AuthService) RenovarToken(ctx context.Context, refreshToken string) (*models.APIResponse, error) {
+	var tokens *models.TokenPair
+	var sessionID uuid.UUID
+	reused := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		users := repositories.NewUserRepository(tx)
+		current, err := users.GetRefreshTokenForUpdate(ctx, hashToken(refreshToken))
+		if err != nil {
+			return ErrInvalidCredentials
+		}
+		sessionID = current.FamilyID
+		if current.RevokedAt != nil {
+			// A revogação precisa ser confirmada, então a recusa sai depois
+			// do commit
+			reused = true
+			return users.RevokeSession(ctx, current.FamilyID)
+		}
+		if time.Now().After(current.ExpiresAt) {
+			return ErrInvalidCredentials
+		}
+
+		// Um usuário removido ou bloqueado não renova; o bloqueio passa e a
+		// sessão continua valendo
+		user, err := users.GetByID(ctx, current.UserID)
+		if err != nil {
+			return ErrInvalidCredentials
+		}
+		if usuarioBloqueado(user, time.Now()) {
+			return ErrUserLocked
+		}
+
+		var nextID uuid.UUID
+		tokens, nextID, err = s.emitirTokens(ctx, users, user, current.FamilyID)
+		if err != nil {
+			return err
+		}
+		if err := users.TouchSession(ctx, current.FamilyID, audit.IP(ctx)); err != nil {
+			return err
+		}
+		return users.RevokeRefreshToken(ctx, current.ID, &nextID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		s.sessions.marcarRevogada(sessionID)
+		return nil, ErrInvalidCredentials
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: "Token renovado com sucesso",
+		Data:    tokens,
+	}, nil
+}
+
+// Logout encerra a sessão do refresh token, revogando os demais tokens da
+// mesma família.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) (*models.APIResponse, error) {
+	token, err := s.users.GetRefreshTokenForUpdate(ctx, hashToken(refreshToken))
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if session, err := s.users.GetSession(ctx, token.FamilyID); err == nil {
+		if session.RevokedAt == nil {
+			if err := s.sessions.revogar(ctx, session, audit.ActorFromContext(ctx)); err != nil {
+				return nil, err
+			}
+		}
+	} else if err := s.users.RevokeRefreshTokenFamily(ctx, token.FamilyID); err != nil {
+		return nil, err
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: "Logout realizado com sucesso",
+		Data:    nil,
+	}, nil
+}
+
+// DefinirPIN cadastra ou redefine o PIN de transação do usuário autenticado.
+// A senha é conferida de novo, e redefinir o PIN também o desbloqueia.
+func (s *AuthService) DefinirPIN(ctx context.Context, senha string, pin string) (*models.APIResponse, error) {
+	if !pinValido(pin) {
+		return &models.APIResponse{
+			Success: false,
+			Message: "O PIN deve ter de 4 a 6 dígitos",
+			Data:    nil,
+		}, nil
+	}
+
+	user, err := s.usuarioAutenticado(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.conferirSenha(ctx, user, senha); err != nil {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		users := repositories.NewUserRepository(tx)
+		stored := &models.TransactionPIN{
+			UserID:    user.ID,
+			PINHash:   string(hash),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+
+		action := "user.pin_set"
+		var before *models.TransactionPIN
+		if current, err := users.GetPINForUpdate(ctx, user.ID); err == nil {
+			action = "user.pin_reset"
+			before = current
+			stored.CreatedAt = current.CreatedAt
+		}
+		if err := users.SavePIN(ctx, stored); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActorFromContext(ctx), action, "user", user.ID.String(), before, stored)
+	})
+	if err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao definir PIN: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: "PIN de transação definido com sucesso",
+		Data:    nil,
+	}, nil
+}
+
+// emitirTokens assina um access token e grava um novo refresh token na
+// família informada, que é também o ID da sessão (claim sid). Devolve
+// também o ID do refresh token gravado.
+func (s *AuthService) emitirTokens(ctx context.Context, users *repositories.UserRepository, user *models.User, familyID uuid.UUID) (*models.TokenPair, uuid.UUID, error) {
+	role := user.Role
+	if role == "" {
+		role = string(auth.RoleCustomer)
+	}
+	accessToken, err := s.issuer.Issue(user.ID.String(), jwt.MapClaims{
+		"roles": []string{role},
+		"sid":   familyID.String(),
+	})
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	refreshToken, err := randomToken()
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	stored := &models.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+		CreatedAt: time.Now(),
+	}
+	if err := users.CreateRefreshToken(ctx, stored); err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.issuer.TTL().Seconds()),
+	}, stored.ID, nil
+}
+
+func normalizarEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// randomToken gera um token opaco de 256 bits. Só o hash é guardado.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/red-velvet-workspace/banco-digital/internal/domain/models"
+)
+
+func TestUsuarioBloqueado(t *testing.T) {
+	agora := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	ate := func(d time.Duration) *time.Time {
+		t := agora.Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name        string
+		lockedUntil *time.Time
+		want        bool
+	}{
+		{"nunca bloqueado", nil, false},
+		{"bloqueio em curso", ate(time.Minute), true},
+		{"bloqueio vencido", ate(-time.Minute), false},
+		{"bloqueio terminando agora", ate(0), false},
+	}
+	for _, tt := range tests {
+		user := &models.User{LockedUntil: tt.lockedUntil}
+		if got := usuarioBloqueado(user, agora); got != tt.want {
+			t.Errorf("%s: bloqueado = %v, esperado %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/red-velvet-workspace/banco-digital/internal/audit"
+	"github.com/red-velvet-workspace/banco-digital/internal/domain/models"
+	"github.com/red-velvet-workspace/banco-digital/internal/infrastructure/kafka"
+)
+
+const (
+	defaultAuthorizationValidity = 7 * 24 * time.Hour
+	maxAuthorizationValidity     = 30 * 24 * time.Hour
+)
+
+// AutorizarCompraCartao reserva o valor da compra no limite sem efetivá-la.
+// A reserva vale até validade (padrão de sete dias) e depois é liberada
+// automaticamente se não for capturada.
+func (s *AccountService) AutorizarCompraCartao(ctx context.Context, req models.CardPurchaseRequest, validade time.Duration) (*models.APIResponse, error) {
+	if err := s.autorizarConta(ctx, req.AccountID); err != nil {
+		return nil, err
+	}
+	if validade <= 0 {
+		validade = defaultAuthorizationValidity
+	}
+	if validade > maxAuthorizationValidity {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("Validade máxima da pré-autorização é de %d dias", int(maxAuthorizationValidity.Hours()/24)),
+			Data:    nil,
+		}, nil
+	}
+
+	transaction, rejection := s.prepararCompraCartao(ctx, req)
+	if rejection != nil {
+		return rejection, nil
+	}
+
+	message := kafka.TransactionMessage{
+		Event:       kafka.NewEvent(),
+		Operation:   "AUTHORIZE",
+		Transaction: *transaction,
+		ExpiresAt:   transaction.CreatedAt.Add(validade),
+		Actor:       audit.ActorFromContext(ctx),
+	}
+	if err := s.producer.PublishMessage(kafka.TopicTransactions, message.PartitionKey(), message); err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao processar pré-autorização: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	authorization := models.CardAuthorization{
+		ID:            transaction.ID,
+		AccountID:     transaction.AccountID,
+		CreditCardID:  *transaction.CreditCardID,
+		VirtualCardID: transaction.VirtualCardID,
+		Amount:        transaction.Amount,
+		Description:   transaction.Description,
+		Installments:  transaction.Installments,
+		InterestRate:  transaction.InterestRate,
+		MerchantName:  transaction.MerchantName,
+		MCC:           transaction.MCC,
+		Category:      transaction.Category,
+		Country:       transaction.Country,
+		Channel:       transaction.Channel,
+		Status:        models.AuthorizationPending,
+		ExpiresAt:     message.ExpiresAt,
+		CreatedAt:     transaction.CreatedAt,
+		UpdatedAt:     transaction.CreatedAt,
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Pré-autorização em processamento: R$ %.2f", transaction.Amount),
+		Data:    authorization,
+	}, nil
+}
+
+// CapturarAutorizacao efetiva a compra pré-autorizada. Sem valor a captura
+// é total; com valor menor, a diferença volta ao limite.
+func (s *AccountService) CapturarAutorizacao(ctx context.Context, accountID uuid.UUID, authorizationID uuid.UUID, valor *float64) (*models.APIResponse, error) {
+	if err := s.autorizarConta(ctx, accountID); err != nil {
+		return nil, err
+	}
+	authorization, reason := s.buscarAutorizacaoPendente(ctx, accountID, authorizationID)
+	if authorization == nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: reason,
+			Data:    nil,
+		}, nil
+	}
+
+	amount := authorization.Amount
+	if valor != nil {
+		if *valor <= 0 || *valor > authorization.Amount {
+			return &models.APIResponse{
+				Success: false,
+				Message: fmt.Sprintf("Valor da captura deve estar entre R$ 0,01 e R$ %.2f", authorization.Amount),
+				Data:    nil,
+			}, nil
+		}
+		amount = models.RoundCents(*valor)
+	}
+
+	cardID := authorization.CreditCardID
+	authID := authorization.ID
+	transaction := models.Transaction{
+		ID:              uuid.New(),
+		AccountID:       authorization.AccountID,
+		Type:            models.CardPurchase,
+		Amount:          amount,
+		Description:     authorization.Description,
+		CreditCardID:    &cardID,
+		VirtualCardID:   authorization.VirtualCardID,
+		Installments:    authorization.Installments,
+		InterestRate:    authorization.InterestRate,
+		AuthorizationID: &authID,
+		MerchantName:    authorization.MerchantName,
+		MCC:             authorization.MCC,
+		Category:        authorization.Category,
+		Country:         authorization.Country,
+		Channel:         authorization.Channel,
+		CreatedAt:       time.Now(),
+	}
+
+	message := kafka.TransactionMessage{
+		Event:       kafka.NewEvent(),
+		Operation:   "CREATE",
+		Transaction: transaction,
+		Actor:       audit.ActorFromContext(ctx),
+	}
+	if err := s.producer.PublishMessage(kafka.TopicTransactions, message.PartitionKey(), message); err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao processar captura: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	msgSuccess := fmt.Sprintf("Captura em processamento: R$ %.2f", amount)
+	_ = s.createNotification(ctx, authorization.AccountID, "TRANSACTION_PENDING", msgSuccess)
+
+	return &models.APIResponse{
+		Success: true,
+		Message: msgSuccess,
+		Data:    transaction,
+	}, nil
+}
+
+// CancelarAutorizacao libera o valor reservado por uma pré-autorização.
+func (s *AccountService) CancelarAutorizacao(ctx context.Context, accountID uuid.UUID, authorizationID uuid.UUID) (*models.APIResponse, error) {
+	if err := s.autorizarConta(ctx, accountID); err != nil {
+		return nil, err
+	}
+	authorization, reason := s.buscarAutorizacaoPendente(ctx, accountID, authorizationID)
+	if authorization == nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: reason,
+			Data:    nil,
+		}, nil
+	}
+
+	if err := s.publicarLiberacaoAutorizacao(authorization, "VOID", audit.ActorFromContext(ctx)); err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao cancelar pré-autorização: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Cancelamento de pré-autorização em processamento: R$ %.2f", authorization.Amount),
+		Data:    map[string]interface{}{"authorization_id": authorizationID},
+	}, nil
+}
+
+// ExpirarAutorizacoes publica a expiração das pré-autorizações vencidas.
+// O consumidor ignora autorizações que já não estão pendentes, então
+// várias réplicas podem rodar a varredura ao mesmo tempo.
+func (s *AccountService) ExpirarAutorizacoes(ctx context.Context) error {
+	var authorizations []models.CardAuthorization
+	if err := s.db.WithContext(ctx).
+		Where("status = ? AND expires_at <= ?", models.AuthorizationPending, time.Now()).
+		Find(&authorizations).Error; err != nil {
+		return err
+	}
+
+	for i := range authorizations {
+		if err := s.publicarLiberacaoAutorizacao(&authorizations[i], "EXPIRE", audit.System); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IniciarExpiracaoAutorizacoes roda ExpirarAutorizacoes a cada intervalo até
+// o contexto ser cancelado.
+func (s *AccountService) IniciarExpiracaoAutorizacoes(ctx context.Context, intervalo time.Duration) {
+	ticker := time.NewTicker(intervalo)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.ExpirarAutorizacoes(ctx); err != nil {
+				log.Printf("[ERROR] Erro ao expirar pré-autorizações: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *AccountService) buscarAutorizacaoPendente(ctx context.Context, accountID uuid.UUID, authorizationID uuid.UUID) (*models.CardAuthorization, string) {
+	var authorization models.CardAuthorization
+	if err := s.db.WithContext(ctx).First(&authorization, "id = ?", authorizationID).Error; err != nil {
+		return nil, "Pré-autorização não encontrada"
+	}
+	if authorization.AccountID != accountID {
+		return nil, "Pré-autorização não pertence à conta informada"
+	}
+	if authorization.Status != models.AuthorizationPending {
+		return nil, fmt.Sprintf("Pré-autorização não está pendente: %s", authorization.Status)
+	}
+	if time.Now().After(authorization.ExpiresAt) {
+		return nil, "Pré-autorização expirada"
+	}
+	return &authorization, ""
+}
+
+func (s *AccountService) publicarLiberacaoAutorizacao(authorization *models.CardAuthorization, operation string, actor audit.Actor) error {
+	authID := authorization.ID
+	cardID := authorization.CreditCardID
+	message := kafka.TransactionMessage{
+		Event:     kafka.NewEvent(),
+		Operation: operation,
+		Transaction: models.Transaction{
+			ID:              uuid.New(),
+			AccountID:       authorization.AccountID,
+			Type:            models.CardPurchase,
+			Amount:          authorization.Amount,
+			CreditCardID:    &cardID,
+			AuthorizationID: &authID,
+			CreatedAt:       time.Now(),
+		},
+		Actor: actor,
+	}
+	return s.producer.PublishMessage(kafka.TopicTransactions, message.PartitionKey(), message)
+}
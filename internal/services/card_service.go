@@ -0,0 +1,614 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/red-velvet-workspace/banco-digital/internal/audit"
+	"github.com/red-velvet-workspace/banco-digital/internal/domain/models"
+	"github.com/red-velvet-workspace/banco-digital/internal/infrastructure/kafka"
+	"github.com/red-velvet-workspace/banco-digital/internal/repositories"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxInstallments = 12
+	// Taxa de juros mensal do parcelamento com juros
+	installmentInterestRate = 0.0299
+	// Taxa de desconto mensal na antecipação de parcelas sem juros
+	earlyPayoffDiscountRate = 0.01
+
+	virtualCardBIN           = "453201"
+	virtualCardValidityYears = 2
+)
+
+// prepararCompraCartao valida a compra e monta a transação correspondente.
+// Quando a compra não pode ser feita devolve a resposta de recusa.
+func (s *AccountService) prepararCompraCartao(ctx context.Context, req models.CardPurchaseRequest) (*models.Transaction, *models.APIResponse) {
+	if req.Installments < 1 {
+		req.Installments = 1
+	}
+	if req.Installments > maxInstallments {
+		return nil, recusarCompra(fmt.Sprintf("Número máximo de parcelas é %d", maxInstallments), "")
+	}
+
+	// Compras com cartão virtual usam o limite do cartão de origem
+	var virtualCard *models.VirtualCard
+	if req.VirtualCardID != nil {
+		virtualCard = &models.VirtualCard{}
+		if err := s.db.WithContext(ctx).First(virtualCard, "id = ?", *req.VirtualCardID).Error; err != nil {
+			return nil, recusarCompra("Cartão virtual não encontrado", "")
+		}
+		if req.CreditCardID != uuid.Nil && req.CreditCardID != virtualCard.CreditCardID {
+			return nil, recusarCompra("Cartão virtual não pertence ao cartão informado", "")
+		}
+		if msg := validarCartaoVirtual(virtualCard); msg != "" {
+			return nil, recusarCompra(msg, "")
+		}
+		req.CreditCardID = virtualCard.CreditCardID
+	}
+
+	var card models.CreditCard
+	if err := s.db.WithContext(ctx).First(&card, "id = ?", req.CreditCardID).Error; err != nil {
+		return nil, recusarCompra("Cartão não encontrado", "")
+	}
+	if card.AccountID != req.AccountID {
+		return nil, recusarCompra("Cartão não pertence à conta informada", "")
+	}
+	if card.Status != "" && card.Status != models.CardActive {
+		return nil, recusarCompra("Cartão bloqueado", "")
+	}
+	var account models.Account
+	if err := s.db.WithContext(ctx).Select("id", "status").First(&account, "id = ?", req.AccountID).Error; err != nil {
+		return nil, recusarCompra("Conta não encontrada", "")
+	}
+	if account.Status == models.AccountFrozen {
+		return nil, recusarCompra("Conta congelada", "")
+	}
+
+	// Com juros o valor total é a soma das parcelas pela tabela Price
+	var rate float64
+	total := models.RoundCents(req.Amount)
+	if req.WithInterest && req.Installments > 1 {
+		rate = installmentInterestRate
+		total = models.RoundCents(models.InstallmentPayment(req.Amount, req.Installments, rate) * float64(req.Installments))
+	}
+
+	if virtualCard != nil && virtualCard.SpendingLimit != nil &&
+		virtualCard.SpentAmount+total > *virtualCard.SpendingLimit {
+		return nil, recusarCompra("Limite do cartão virtual excedido", "")
+	}
+
+	// O valor total fica reservado no limite até o pagamento das parcelas
+	if card.AvailableLimit < total {
+		return nil, recusarCompra("Limite insuficiente", "")
+	}
+
+	category := models.CategoryForMCC(req.MCC)
+	if rejection := s.verificarControlesCartao(ctx, card.ID, category, req, total); rejection != nil {
+		return nil, rejection
+	}
+
+	description := req.Description
+	if description == "" && req.Installments > 1 {
+		description = fmt.Sprintf("Compra parcelada em %dx", req.Installments)
+	}
+
+	cardID := card.ID
+	transaction := models.Transaction{
+		ID:            uuid.New(),
+		AccountID:     req.AccountID,
+		Type:          models.CardPurchase,
+		Amount:        total,
+		Description:   description,
+		CreditCardID:  &cardID,
+		VirtualCardID: req.VirtualCardID,
+		Installments:  req.Installments,
+		InterestRate:  rate,
+		MerchantName:  req.MerchantName,
+		MCC:           req.MCC,
+		Category:      category,
+		Country:       req.Country,
+		Channel:       req.Channel,
+		CreatedAt:     time.Now(),
+	}
+
+	return &transaction, nil
+}
+
+// verificarControlesCartao aplica os controles definidos pelo portador. O
+// gasto do mês na categoria inclui compras e pré-autorizações pendentes.
+func (s *AccountService) verificarControlesCartao(ctx context.Context, cardID uuid.UUID, category models.MerchantCategory, req models.CardPurchaseRequest, amount float64) *models.APIResponse {
+	var controls models.CardControls
+	err := s.db.WithContext(ctx).Limit(1).Find(&controls, "credit_card_id = ?", cardID).Error
+	if err != nil {
+		return recusarCompra(fmt.Sprintf("erro ao obter controles do cartão: %v", err), "")
+	}
+	if controls.CreditCardID == uuid.Nil {
+		return nil
+	}
+
+	var monthSpent float64
+	if _, limited := controls.CategoryLimits[category]; limited {
+		now := time.Now()
+		monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		var purchases, holds float64
+		if err := s.db.WithContext(ctx).Model(&models.Transaction{}).
+			Where("credit_card_id = ? AND type = ? AND category = ? AND created_at >= ?",
+				cardID, models.CardPurchase, category, monthStart).
+			Select("COALESCE(SUM(amount), 0)").Scan(&purchases).Error; err != nil {
+			return recusarCompra(fmt.Sprintf("erro ao calcular gasto na categoria: %v", err), "")
+		}
+		if err := s.db.WithContext(ctx).Model(&models.CardAuthorization{}).
+			Where("credit_card_id = ? AND status = ? AND category = ? AND created_at >= ?",
+				cardID, models.AuthorizationPending, category, monthStart).
+			Select("COALESCE(SUM(amount), 0)").Scan(&holds).Error; err != nil {
+			return recusarCompra(fmt.Sprintf("erro ao calcular gasto na categoria: %v", err), "")
+		}
+		monthSpent = purchases + holds
+	}
+
+	code := controls.Check(category, req.Channel, req.Country, amount, monthSpent)
+	switch code {
+	case models.DeclineCategoryBlocked:
+		return recusarCompra(fmt.Sprintf("Compras na categoria %s estão bloqueadas neste cartão", category), code)
+	case models.DeclineOnlineDisabled:
+		return recusarCompra("Compras online estão desativadas neste cartão", code)
+	case models.DeclineInternationalDisabled:
+		return recusarCompra("Compras internacionais estão desativadas neste cartão", code)
+	case models.DeclineCategoryLimitExceeded:
+		return recusarCompra(fmt.Sprintf("Limite mensal da categoria %s excedido", category), code)
+	}
+	return nil
+}
+
+func recusarCompra(message string, code string) *models.APIResponse {
+	return &models.APIResponse{
+		Success: false,
+		Message: message,
+		Data:    nil,
+		Code:    code,
+	}
+}
+
+func (s *AccountService) RealizarCompraCartao(ctx context.Context, req models.CardPurchaseRequest) (*models.APIResponse, error) {
+	if err := s.autorizarConta(ctx, req.AccountID); err != nil {
+		return nil, err
+	}
+	transaction, rejection := s.prepararCompraCartao(ctx, req)
+	if rejection != nil {
+		return rejection, nil
+	}
+
+	message := kafka.TransactionMessage{
+		Event:       kafka.NewEvent(),
+		Operation:   "CREATE",
+		Transaction: *transaction,
+		Actor:       audit.ActorFromContext(ctx),
+	}
+	if err := s.producer.PublishMessage(kafka.TopicTransactions, message.PartitionKey(), message); err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao processar compra: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	msgSuccess := fmt.Sprintf("Compra em processamento: R$ %.2f", transaction.Amount)
+	if transaction.Installments > 1 {
+		msgSuccess = fmt.Sprintf("Compra em processamento: %dx de R$ %.2f (total R$ %.2f)",
+			transaction.Installments, models.SplitInstallments(transaction.Amount, transaction.Installments)[transaction.Installments-1], transaction.Amount)
+	}
+	_ = s.createNotification(ctx, req.AccountID, "TRANSACTION_PENDING", msgSuccess)
+
+	return &models.APIResponse{
+		Success: true,
+		Message: msgSuccess,
+		Data:    *transaction,
+	}, nil
+}
+
+func (s *AccountService) ObterControlesCartao(ctx context.Context, cardID uuid.UUID) (*models.APIResponse, error) {
+	if _, err := s.autorizarCartao(ctx, cardID); err != nil {
+		return nil, err
+	}
+	controls := models.CardControls{CreditCardID: cardID}
+	if err := s.db.WithContext(ctx).Limit(1).Find(&controls, "credit_card_id = ?", cardID).Error; err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao obter controles do cartão: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: "Controles do cartão obtidos com sucesso",
+		Data:    controls,
+	}, nil
+}
+
+// ConfigurarControlesCartao substitui os controles de uso do cartão.
+func (s *AccountService) ConfigurarControlesCartao(ctx context.Context, controls models.CardControls) (*models.APIResponse, error) {
+	card, err := s.autorizarCartao(ctx, controls.CreditCardID)
+	if err != nil {
+		return nil, err
+	}
+
+	for category, limit := range controls.CategoryLimits {
+		if limit < 0 {
+			return &models.APIResponse{
+				Success: false,
+				Message: fmt.Sprintf("Limite da categoria %s não pode ser negativo", category),
+				Data:    nil,
+			}, nil
+		}
+	}
+
+	var before *models.CardControls
+	var current models.CardControls
+	if result := s.db.WithContext(ctx).Limit(1).Find(&current, "credit_card_id = ?", controls.CreditCardID); result.Error == nil && result.RowsAffected > 0 {
+		before = &current
+	}
+
+	now := time.Now()
+	controls.CreatedAt = now
+	controls.UpdatedAt = now
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "credit_card_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"blocked_categories", "online_disabled", "international_disabled", "category_limits", "updated_at"}),
+		}).Create(&controls).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActorFromContext(ctx), "card_controls.update", "credit_card", controls.CreditCardID.String(), before, controls)
+	}); err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao salvar controles do cartão: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	if err := s.createNotification(ctx, card.AccountID, "CARD_CONTROLS_CHANGE",
+		"Os controles de uso do seu cartão foram atualizados"); err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao criar notificação de controles do cartão: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: "Controles do cartão atualizados com sucesso",
+		Data:    controls,
+	}, nil
+}
+
+// PagarFatura paga a fatura do cartão com o saldo da conta. Sem faturaID o
+// valor é abatido das faturas fechadas e depois da aberta; o excedente vira
+// saldo credor no cartão.
+func (s *AccountService) PagarFatura(ctx context.Context, accountID uuid.UUID, cardID uuid.UUID, valor float64, faturaID *uuid.UUID) (*models.APIResponse, error) {
+	if err := s.autorizarConta(ctx, accountID); err != nil {
+		return nil, err
+	}
+	var card models.CreditCard
+	if err := s.db.WithContext(ctx).First(&card, "id = ?", cardID).Error; err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Cartão não encontrado",
+			Data:    nil,
+		}, nil
+	}
+	if card.AccountID != accountID {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Cartão não pertence à conta informada",
+			Data:    nil,
+		}, nil
+	}
+
+	if faturaID != nil {
+		var invoice models.Invoice
+		if err := s.db.WithContext(ctx).
+			First(&invoice, "id = ? AND credit_card_id = ?", *faturaID, cardID).Error; err != nil {
+			return &models.APIResponse{
+				Success: false,
+				Message: "Fatura não encontrada",
+				Data:    nil,
+			}, nil
+		}
+		if invoice.Status == models.InvoicePaid {
+			return &models.APIResponse{
+				Success: false,
+				Message: "Fatura já está paga",
+				Data:    nil,
+			}, nil
+		}
+	}
+
+	// Validação otimista; o consumidor confirma o saldo na mesma transação
+	// que debita a conta
+	var account models.Account
+	if err := s.db.WithContext(ctx).First(&account, "id = ?", accountID).Error; err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Conta não encontrada",
+			Data:    nil,
+		}, nil
+	}
+	if account.Balance < valor {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Saldo insuficiente",
+			Data:    nil,
+		}, nil
+	}
+
+	transaction := models.Transaction{
+		ID:           uuid.New(),
+		AccountID:    accountID,
+		Type:         models.CardPayment,
+		Amount:       models.RoundCents(valor),
+		Description:  "Pagamento de fatura",
+		CreditCardID: &cardID,
+		RelatedID:    faturaID,
+		CreatedAt:    time.Now(),
+	}
+
+	message := kafka.TransactionMessage{
+		Event:       kafka.NewEvent(),
+		Operation:   "CREATE",
+		Transaction: transaction,
+		Actor:       audit.ActorFromContext(ctx),
+	}
+	if err := s.producer.PublishMessage(kafka.TopicTransactions, message.PartitionKey(), message); err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao processar pagamento: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	msgSuccess := fmt.Sprintf("Pagamento de fatura em processamento: R$ %.2f", valor)
+	_ = s.createNotification(ctx, accountID, "TRANSACTION_PENDING", msgSuccess)
+
+	return &models.APIResponse{
+		Success: true,
+		Message: msgSuccess,
+		Data:    transaction,
+	}, nil
+}
+
+func (s *AccountService) ListarFaturas(ctx context.Context, cardID uuid.UUID) (*models.APIResponse, error) {
+	if _, err := s.autorizarCartao(ctx, cardID); err != nil {
+		return nil, err
+	}
+	repo := repositories.NewInvoiceRepository(s.db)
+	if err := repo.CloseDueInvoices(ctx, cardID); err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao fechar faturas: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	invoices, err := repo.GetByCardID(ctx, cardID)
+	if err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao obter faturas: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: "Faturas obtidas com sucesso",
+		Data:    invoices,
+	}, nil
+}
+
+// cotarAntecipacao calcula o valor presente das parcelas pendentes de uma
+// compra, descontando cada parcela pelos meses que faltam até o vencimento.
+func (s *AccountService) cotarAntecipacao(ctx context.Context, accountID uuid.UUID, purchaseID uuid.UUID) (*models.Transaction, *models.EarlyPayoffQuote, error) {
+	var purchase models.Transaction
+	if err := s.db.WithContext(ctx).
+		First(&purchase, "id = ? AND type = ?", purchaseID, models.CardPurchase).Error; err != nil {
+		return nil, nil, errors.New("Compra não encontrada")
+	}
+	if purchase.AccountID != accountID {
+		return nil, nil, errors.New("Compra não pertence à conta informada")
+	}
+
+	installments, err := repositories.NewInvoiceRepository(s.db).GetPendingInstallments(ctx, purchaseID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("erro ao obter parcelas: %v", err)
+	}
+	if len(installments) == 0 {
+		return nil, nil, errors.New("Compra não possui parcelas pendentes")
+	}
+
+	rate := purchase.InterestRate
+	if rate <= 0 {
+		rate = earlyPayoffDiscountRate
+	}
+
+	now := time.Now()
+	quote := &models.EarlyPayoffQuote{
+		PurchaseID:            purchaseID,
+		RemainingInstallments: len(installments),
+	}
+	invoices := repositories.NewInvoiceRepository(s.db)
+	for _, installment := range installments {
+		// Só a parte ainda não paga na fatura é antecipada
+		outstanding, err := invoices.OutstandingAmount(ctx, installment)
+		if err != nil {
+			return nil, nil, fmt.Errorf("erro ao obter parcelas: %v", err)
+		}
+		months := monthsBetween(now, installment.DueDate)
+		quote.NominalAmount += outstanding
+		quote.DiscountedAmount += outstanding / math.Pow(1+rate, float64(months))
+	}
+	quote.NominalAmount = models.RoundCents(quote.NominalAmount)
+	quote.DiscountedAmount = models.RoundCents(quote.DiscountedAmount)
+	quote.Discount = models.RoundCents(quote.NominalAmount - quote.DiscountedAmount)
+	return &purchase, quote, nil
+}
+
+func (s *AccountService) SimularAntecipacao(ctx context.Context, accountID uuid.UUID, purchaseID uuid.UUID) (*models.APIResponse, error) {
+	if err := s.autorizarConta(ctx, accountID); err != nil {
+		return nil, err
+	}
+	_, quote, err := s.cotarAntecipacao(ctx, accountID, purchaseID)
+	if err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+			Data:    nil,
+		}, nil
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Antecipação de %d parcelas por R$ %.2f (desconto de R$ %.2f)",
+			quote.RemainingInstallments, quote.DiscountedAmount, quote.Discount),
+		Data: quote,
+	}, nil
+}
+
+func (s *AccountService) AnteciparParcelas(ctx context.Context, accountID uuid.UUID, purchaseID uuid.UUID) (*models.APIResponse, error) {
+	if err := s.autorizarConta(ctx, accountID); err != nil {
+		return nil, err
+	}
+	purchase, quote, err := s.cotarAntecipacao(ctx, accountID, purchaseID)
+	if err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+			Data:    nil,
+		}, nil
+	}
+
+	var account models.Account
+	if err := s.db.WithContext(ctx).First(&account, "id = ?", accountID).Error; err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Conta não encontrada",
+			Data:    nil,
+		}, nil
+	}
+	if account.Balance < quote.DiscountedAmount {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Saldo insuficiente",
+			Data:    nil,
+		}, nil
+	}
+
+	purchaseRef := purchase.ID
+	transaction := models.Transaction{
+		ID:           uuid.New(),
+		AccountID:    accountID,
+		Type:         models.CardPrepayment,
+		Amount:       quote.DiscountedAmount,
+		Description:  fmt.Sprintf("Antecipação de %d parcelas", quote.RemainingInstallments),
+		CreditCardID: purchase.CreditCardID,
+		RelatedID:    &purchaseRef,
+		CreatedAt:    time.Now(),
+	}
+
+	message := kafka.TransactionMessage{
+		Event:       kafka.NewEvent(),
+		Operation:   "CREATE",
+		Transaction: transaction,
+		Actor:       audit.ActorFromContext(ctx),
+	}
+	if err := s.producer.PublishMessage(kafka.TopicTransactions, message.PartitionKey(), message); err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao processar antecipação: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	msgSuccess := fmt.Sprintf("Antecipação em processamento: R$ %.2f (desconto de R$ %.2f)",
+		quote.DiscountedAmount, quote.Discount)
+	_ = s.createNotification(ctx, accountID, "TRANSACTION_PENDING", msgSuccess)
+
+	return &models.APIResponse{
+		Success: true,
+		Message: msgSuccess,
+		Data:    transaction,
+	}, nil
+}
+
+// validarCartaoVirtual devolve o motivo pelo qual o cartão virtual não pode
+// ser usado, ou vazio se puder.
+func validarCartaoVirtual(card *models.VirtualCard) string {
+	switch {
+	case card.Status == models.VirtualCardDeleted:
+		return "Cartão virtual excluído"
+	case card.Status == models.VirtualCardUsed:
+		return "Cartão virtual de uso único já utilizado"
+	case time.Now().After(card.ExpirationDate):
+		return "Cartão virtual expirado"
+	}
+	return ""
+}
+
+// generateCardNumber gera um número de cartão de 16 dígitos com o BIN
+// informado e dígito verificador de Luhn.
+func generateCardNumber(bin string) (string, error) {
+	body, err := randomDigits(15 - len(bin))
+	if err != nil {
+		return "", err
+	}
+	number := bin + body
+
+	sum := 0
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if (len(number)-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return number + strconv.Itoa((10-sum%10)%10), nil
+}
+
+func randomDigits(n int) (string, error) {
+	digits := make([]byte, n)
+	for i := range digits {
+		d, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		digits[i] = byte('0' + d.Int64())
+	}
+	return string(digits), nil
+}
+
+// monthsBetween conta os meses inteiros entre from e to, nunca negativo.
+func monthsBetween(from, to time.Time) int {
+	months := (to.Year()-from.Year())*12 + int(to.Month()-from.Month())
+	if to.Day() < from.Day() {
+		months--
+	}
+	if months < 0 {
+		return 0
+	}
+	return months
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/red-velvet-workspace/banco-digital/internal/auth"
+	"github.com/red-velvet-workspace/banco-digital/internal/domain/models"
+)
+
+// ErrForbidden indica que o autor da requisição não tem acesso à conta ou ao
+// cartão informado. Os handlers HTTP respondem 403 para este erro.
+var ErrForbidden = errors.New("acesso negado")
+
+// autorizarConta verifica se o principal do contexto é dono da conta ou tem
+// acesso delegado a ela. Contas inexistentes também são negadas, para não
+// revelar quais IDs existem.
+func (s *AccountService) autorizarConta(ctx context.Context, accountID uuid.UUID) error {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return ErrForbidden
+	}
+
+	var account models.Account
+	if err := s.db.WithContext(ctx).Select("id", "owner_id").First(&account, "id = ?", accountID).Error; err != nil {
+		return ErrForbidden
+	}
+	if !principal.CanAccessAccount(account.ID, account.OwnerID) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// exigirPermissao verifica se algum papel do principal concede a permissão.
+// Operações da equipe do banco usam esta verificação no lugar da posse.
+func exigirPermissao(ctx context.Context, permission auth.Permission) error {
+	principal, ok := auth.FromContext(ctx)
+	if !ok || !principal.Can(permission) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// principalUsuario devolve o principal e o ID do usuário logado. Chaves de
+// API e tokens sem usuário cadastrado são recusados.
+func principalUsuario(ctx context.Context) (*auth.Principal, uuid.UUID, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok || principal.APIKeyID != nil {
+		return nil, uuid.Nil, ErrForbidden
+	}
+	userID, err := uuid.Parse(principal.Subject)
+	if err != nil {
+		return nil, uuid.Nil, ErrForbidden
+	}
+	return principal, userID, nil
+}
+
+// autorizarCartao verifica o acesso à conta dona do cartão.
+func (s *AccountService) autorizarCartao(ctx context.Context, cardID uuid.UUID) (*models.CreditCard, error) {
+	var card models.CreditCard
+	if err := s.db.WithContext(ctx).First(&card, "id = ?", cardID).Error; err != nil {
+		return nil, ErrForbidden
+	}
+	if err := s.autorizarConta(ctx, card.AccountID); err != nil {
+		return nil, err
+	}
+	return &card, nil
+}
+
+// autorizarCartaoVirtual verifica o acesso à conta dona do cartão de origem.
+func (s *AccountService) autorizarCartaoVirtual(ctx context.Context, virtualCardID uuid.UUID) error {
+	var card models.VirtualCard
+	if err := s.db.WithContext(ctx).Select("id", "credit_card_id").First(&card, "id = ?", virtualCardID).Error; err != nil {
+		return ErrForbidden
+	}
+	_, err := s.autorizarCartao(ctx, card.CreditCardID)
+	return err
+}
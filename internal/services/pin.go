@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/red-velvet-workspace/banco-digital/internal/audit"
+	"github.com/red-velvet-workspace/banco-digital/internal/auth"
+	"github.com/red-velvet-workspace/banco-digital/internal/repositories"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const maxFailedPINs = 3
+
+var (
+	// ErrPINRequired é devolvido quando a operação exige o PIN de transação e
+	// ele não veio no cabeçalho X-Transaction-PIN.
+	ErrPINRequired = errors.New("operação exige o PIN de transação")
+	// ErrPINNotSet é devolvido quando o usuário ainda não cadastrou um PIN.
+	ErrPINNotSet = errors.New("cadastre um PIN de transação em /auth/pin")
+	// ErrPINInvalid é devolvido para um PIN errado.
+	ErrPINInvalid = errors.New("PIN de transação inválido")
+	// ErrPINBlocked é devolvido depois de maxFailedPINs erros seguidos, até o
+	// PIN ser redefinido.
+	ErrPINBlocked = errors.New("PIN de transação bloqueado, redefina-o em /auth/pin")
+)
+
+// PINPolicy define a partir de que valor cada operação exige o PIN de
+// transação. Valores iguais ou abaixo do limiar dispensam o PIN.
+type PINPolicy struct {
+	PixEnvio      float64
+	Saque         float64
+	AumentoLimite float64
+	ChavePix      bool
+}
+
+// LoadPINPolicy lê os limiares das variáveis de ambiente PIN_*.
+func LoadPINPolicy() (PINPolicy, error) {
+	policy := PINPolicy{
+		PixEnvio:      200,
+		Saque:         500,
+		AumentoLimite: 0,
+		ChavePix:      true,
+	}
+
+	thresholds := map[string]*float64{
+		"PIN_LIMIAR_PIX":            &policy.PixEnvio,
+		"PIN_LIMIAR_SAQUE":          &policy.Saque,
+		"PIN_LIMIAR_AUMENTO_LIMITE": &policy.AumentoLimite,
+	}
+	for name, dst := range thresholds {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return policy, fmt.Errorf("%s inválido: %v", name, err)
+		}
+		*dst = f
+	}
+
+	if v := os.Getenv("PIN_CHAVE_PIX"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return policy, fmt.Errorf("PIN_CHAVE_PIX inválido: %v", err)
+		}
+		policy.ChavePix = b
+	}
+	return policy, nil
+}
+
+// pinValido aceita de 4 a 6 dígitos.
+func pinValido(pin string) bool {
+	if len(pin) < 4 || len(pin) > 6 {
+		return false
+	}
+	for _, c := range pin {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// exigePINAumentoLimite informa se o aumento de limite de atual para novo
+// pede o PIN de quem o faz. Todo usuário, inclusive da equipe, confirma
+// aumentos acima do limiar com o próprio PIN; chaves de API não têm PIN e
+// ficam restritas ao escopo limits:override e ao log de auditoria.
+func (p PINPolicy) exigePINAumentoLimite(principal *auth.Principal, atual, novo float64) bool {
+	if principal == nil || principal.APIKeyID != nil {
+		return false
+	}
+	return novo-atual > p.AumentoLimite
+}
+
+// verificarPIN confere o PIN de transação do principal. Cada erro é contado
+// e, ao chegar a maxFailedPINs, o PIN é bloqueado.
+func (s *AccountService) verificarPIN(ctx context.Context) error {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return ErrForbidden
+	}
+	pin := auth.TransactionPIN(ctx)
+	if pin == "" {
+		return ErrPINRequired
+	}
+	userID, err := uuid.Parse(principal.Subject)
+	if err != nil {
+		return ErrPINNotSet
+	}
+
+	// O erro contado precisa ser confirmado, então a recusa sai depois do
+	// commit
+	var result error
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		users := repositories.NewUserRepository(tx)
+		stored, err := users.GetPINForUpdate(ctx, userID)
+		if err != nil {
+			result = ErrPINNotSet
+			return nil
+		}
+		if stored.BlockedAt != nil {
+			result = ErrPINBlocked
+			return nil
+		}
+
+		if bcrypt.CompareHashAndPassword([]byte(stored.PINHash), []byte(pin)) == nil {
+			if stored.FailedAttempts == 0 {
+				return nil
+			}
+			stored.FailedAttempts = 0
+			stored.UpdatedAt = time.Now()
+			return users.SavePIN(ctx, stored)
+		}
+
+		result = ErrPINInvalid
+		stored.FailedAttempts++
+		stored.UpdatedAt = time.Now()
+		if stored.FailedAttempts >= maxFailedPINs {
+			result = ErrPINBlocked
+			stored.BlockedAt = &stored.UpdatedAt
+			if err := audit.Record(tx, audit.ActorFromContext(ctx), "user.pin_blocked", "user", userID.String(), nil, stored); err != nil {
+				return err
+			}
+		}
+		return users.SavePIN(ctx, stored)
+	})
+	if err != nil {
+		return err
+	}
+	return result
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/red-velvet-workspace/banco-digital/internal/auth"
+)
+
+func TestExigePINAumentoLimite(t *testing.T) {
+	staff := &auth.Principal{Subject: uuid.NewString(), Roles: []auth.Role{auth.RoleRisk}}
+	apiKey := auth.NewAPIKeyPrincipal(uuid.New(), []auth.Permission{auth.PermLimitsOverride}, nil)
+	policy := PINPolicy{AumentoLimite: 1000}
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		atual     float64
+		novo      float64
+		want      bool
+	}{
+		{"equipe acima do limiar", staff, 2000, 3500, true},
+		{"equipe no limiar", staff, 2000, 3000, false},
+		{"equipe reduzindo", staff, 2000, 500, false},
+		{"chave de API acima do limiar", apiKey, 2000, 9000, false},
+		{"sem principal", nil, 2000, 9000, false},
+	}
+	for _, tt := range tests {
+		if got := policy.exigePINAumentoLimite(tt.principal, tt.atual, tt.novo); got != tt.want {
+			t.Errorf("%s: exige PIN = %v, esperado %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Com o limiar padrão qualquer aumento pede o PIN
+	if !(PINPolicy{}).exigePINAumentoLimite(staff, 2000, 2000.01) {
+		t.Error("aumento com limiar zero sem PIN")
+	}
+}
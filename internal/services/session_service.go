@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/red-velvet-workspace/banco-digital/internal/audit"
+	"github.com/red-velvet-workspace/banco-digital/internal/domain/models"
+	"github.com/red-velvet-workspace/banco-digital/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// Intervalo mínimo entre atualizações do último acesso de uma sessão
+const sessionTouchInterval = time.Minute
+
+var (
+	// ErrSessionRevoked é devolvido para tokens de uma sessão encerrada.
+	ErrSessionRevoked = errors.New("sessão encerrada")
+	// ErrSessionsNotLoaded é devolvido enquanto a lista de sessões
+	// revogadas nunca foi carregada: sem ela não há como aceitar o token.
+	ErrSessionsNotLoaded = errors.New("lista de sessões revogadas ainda não carregada")
+)
+
+// SessionService mantém a lista de sessões revogadas usada pelo middleware
+// JWT. Cada réplica guarda a lista em memória e a recarrega periodicamente;
+// sessões encerradas pela própria réplica entram na lista na hora.
+type SessionService struct {
+	db        *gorm.DB
+	users     *repositories.UserRepository
+	accessTTL time.Duration
+
+	mu      sync.RWMutex
+	loaded  bool
+	revoked map[uuid.UUID]struct{}
+	touched map[uuid.UUID]time.Time
+}
+
+// NewSessionService cria o serviço e carrega a lista de sessões revogadas;
+// se a carga falhar o serviço não sobe. accessTTL é a validade dos access
+// tokens: sessões encerradas há mais tempo não precisam ficar na lista.
+func NewSessionService(db *gorm.DB, accessTTL time.Duration) (*SessionService, error) {
+	s := &SessionService{
+		db:        db,
+		users:     repositories.NewUserRepository(db),
+		accessTTL: accessTTL,
+		revoked:   make(map[uuid.UUID]struct{}),
+		touched:   make(map[uuid.UUID]time.Time),
+	}
+	if err := s.SincronizarRevogacoes(context.Background()); err != nil {
+		return nil, fmt.Errorf("erro ao carregar sessões revogadas: %w", err)
+	}
+	return s, nil
+}
+
+// ValidateSession recusa tokens de sessões encerradas e registra o último
+// acesso da sessão. Enquanto a lista nunca foi carregada todos os tokens
+// com sessão são recusados.
+func (s *SessionService) ValidateSession(ctx context.Context, sessionID string, ip string) error {
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return ErrSessionRevoked
+	}
+
+	s.mu.RLock()
+	loaded := s.loaded
+	_, revoked := s.revoked[id]
+	lastTouch := s.touched[id]
+	s.mu.RUnlock()
+	if !loaded {
+		return ErrSessionsNotLoaded
+	}
+	if revoked {
+		return ErrSessionRevoked
+	}
+
+	if time.Since(lastTouch) > sessionTouchInterval {
+		s.mu.Lock()
+		s.touched[id] = time.Now()
+		s.mu.Unlock()
+		if err := s.users.TouchSession(ctx, id, ip); err != nil {
+			log.Printf("[ERROR] Erro ao registrar acesso da sessão %s: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// SincronizarRevogacoes recarrega a lista de sessões encerradas dentro da
+// validade dos access tokens.
+func (s *SessionService) SincronizarRevogacoes(ctx context.Context) error {
+	ids, err := s.users.ListRevokedSessionIDs(ctx, time.Now().Add(-s.accessTTL-time.Minute))
+	if err != nil {
+		return err
+	}
+
+	revoked := make(map[uuid.UUID]struct{}, len(ids))
+	for _, id := range ids {
+		revoked[id] = struct{}{}
+	}
+
+	s.mu.Lock()
+	s.revoked = revoked
+	s.loaded = true
+	for id, at := range s.touched {
+		if time.Since(at) > s.accessTTL {
+			delete(s.touched, id)
+		}
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// IniciarSincronizacao roda SincronizarRevogacoes a cada intervalo, até o
+// contexto ser cancelado. A primeira carga já foi feita em NewSessionService;
+// se uma recarga falhar, a lista anterior continua valendo.
+func (s *SessionService) IniciarSincronizacao(ctx context.Context, intervalo time.Duration) {
+	ticker := time.NewTicker(intervalo)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.SincronizarRevogacoes(ctx); err != nil {
+				log.Printf("[ERROR] Erro ao carregar sessões revogadas: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ListarSessoes devolve as sessões ativas do usuário autenticado, marcando
+// a da própria requisição.
+func (s *SessionService) ListarSessoes(ctx context.Context) (*models.APIResponse, error) {
+	principal, userID, err := principalUsuario(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.users.ListActiveSessions(ctx, userID)
+	if err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao listar sessões: %v", err),
+			Data:    nil,
+		}, nil
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID.String() == principal.SessionID
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d sessão(ões) ativa(s)", len(sessions)),
+		Data:    sessions,
+	}, nil
+}
+
+// EncerrarSessao encerra uma sessão do usuário autenticado. Os access tokens
+// dela deixam de valer na hora nesta réplica e, nas demais, na próxima
+// sincronização.
+func (s *SessionService) EncerrarSessao(ctx context.Context, sessionID uuid.UUID) (*models.APIResponse, error) {
+	_, userID, err := principalUsuario(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.users.GetSession(ctx, sessionID)
+	if err != nil || session.UserID != userID {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Sessão não encontrada",
+			Data:    nil,
+		}, nil
+	}
+	if session.RevokedAt != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Sessão já encerrada",
+			Data:    nil,
+		}, nil
+	}
+
+	if err := s.revogar(ctx, session, audit.ActorFromContext(ctx)); err != nil {
+		return &models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("erro ao encerrar sessão: %v", err),
+			Data:    nil,
+		}, nil
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: "Sessão encerrada",
+		Data:    nil,
+	}, nil
+}
+
+// revogar encerra a sessão no banco e a inclui na lista local.
+func (s *SessionService) revogar(ctx context.Context, session *models.Session, actor audit.Actor) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		users := repositories.NewUserRepository(tx)
+		if err := users.RevokeSession(ctx, session.ID); err != nil {
+			return err
+		}
+		after, err := users.GetSession(ctx, session.ID)
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, actor, "session.revoke", "session", session.ID.String(), session, after)
+	})
+	if err != nil {
+		return err
+	}
+	s.marcarRevogada(session.ID)
+	return nil
+}
+
+func (s *SessionService) marcarRevogada(id uuid.UUID) {
+	s.mu.Lock()
+	s.revoked[id] = struct{}{}
+	s.mu.Unlock()
+}
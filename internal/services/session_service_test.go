@@ -0,0 +1,33 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestValidateSessionBeforeFirstSync(t *testing.T) {
+	id := uuid.New()
+	s := &SessionService{
+		accessTTL: time.Hour,
+		revoked:   make(map[uuid.UUID]struct{}),
+		touched:   make(map[uuid.UUID]time.Time),
+	}
+
+	// Sem a lista carregada nenhuma sessão é aceita
+	if err := s.ValidateSession(context.Background(), id.String(), ""); !errors.Is(err, ErrSessionsNotLoaded) {
+		t.Fatalf("antes da carga: erro %v, esperado %v", err, ErrSessionsNotLoaded)
+	}
+
+	s.loaded = true
+	s.revoked[id] = struct{}{}
+	if err := s.ValidateSession(context.Background(), id.String(), ""); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("sessão revogada: erro %v, esperado %v", err, ErrSessionRevoked)
+	}
+	if err := s.ValidateSession(context.Background(), "não-é-uuid", ""); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("sid inválido: erro %v, esperado %v", err, ErrSessionRevoked)
+	}
+}
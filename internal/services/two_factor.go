@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/red-velvet-workspace/banco-digital/internal/audit"
+	"github.com/red-velvet-workspace/banco-digital/internal/auth"
+	"github.com/red-velvet-workspace/banco-digital/internal/domain/models"
+	"github.com/red-velvet-workspace/banco-digital/internal/repositories"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	totpIssuer        = "Banco Digital"
+	totpSkew          = 1
+	recoveryCodeCount = 10
+)
+
+// ErrTOTPRequired é devolvido no login de um dispositivo desconhecido quando
+// o usuário tem o TOTP ativado e o código não foi informado.
+var ErrTOTPRequired = errors.New("informe o código de verificação em duas etapas")
+
+// Dispositivo identifica de onde vem o login. ID vem do cabeçalho
+// X-Device-ID; sem ele o dispositivo é sempre tratado como novo. Nome, do
+// cabeçalho X-Device-Name, aparece na lista de sessões.
+type Dispositivo struct {
+	ID        string
+	Nome      string
+	UserAgent string
+}
+
+func (d Dispositivo) hash() string {
+	if d.ID == "" {
+		return ""
+	}
+	return hashToken(d.ID)
+}
+
+// CadastrarDoisFatores gera um segredo TOTP para o usuário autenticado. O
+// segredo só passa a ser exigido depois de AtivarDoisFatores.
+func (s *AuthService) CadastrarDoisFatores(ctx context.Context) (*models.APIResponse, error) {
+	user, err := s.usuarioAutenticado(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Verificação em duas etapas já está ativada",
+			Data:    nil,
+		}, nil
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.totpBox.Seal(secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.users.SetTOTP(ctx, user.ID, sealed, false); err != nil {
+		return nil, err
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: "Leia o QR code no aplicativo autenticador e confirme com um código",
+		Data: models.TOTPEnrollment{
+			Secret: secret,
+			URI:    auth.TOTPURI(totpIssuer, user.Email, secret),
+		},
+	}, nil
+}
+
+// AtivarDoisFatores confirma o cadastro com um código do autenticador e
+// devolve os códigos de recuperação, exibidos só desta vez. Os outros
+// dispositivos conhecidos são esquecidos.
+func (s *AuthService) AtivarDoisFatores(ctx context.Context, codigo string, dispositivo Dispositivo) (*models.APIResponse, error) {
+	user, err := s.usuarioAutenticado(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Verificação em duas etapas já está ativada",
+			Data:    nil,
+		}, nil
+	}
+	if user.TOTPSecret == "" {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Cadastre a verificação em duas etapas antes de ativá-la",
+			Data:    nil,
+		}, nil
+	}
+
+	secret, err := s.totpBox.Open(user.TOTPSecret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := auth.VerifyTOTP(secret, strings.TrimSpace(codigo), time.Now(), totpSkew)
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := novoCodigoRecuperacao()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashToken(normalizarCodigoRecuperacao(code)))
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		users := repositories.NewUserRepository(tx)
+		if err := users.SetTOTP(ctx, user.ID, user.TOTPSecret, true); err != nil {
+			return err
+		}
+		if _, err := users.UseTOTPStep(ctx, user.ID, step); err != nil {
+			return err
+		}
+		if err := users.ReplaceRecoveryCodes(ctx, user.ID, hashes); err != nil {
+			return err
+		}
+		if err := users.ForgetDevices(ctx, user.ID, dispositivo.hash()); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActorFromContext(ctx), "user.totp_enable", "user", user.ID.String(),
+			map[string]bool{"totp_enabled": false}, map[string]bool{"totp_enabled": true})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: "Verificação em duas etapas ativada. Guarde os códigos de recuperação",
+		Data:    map[string][]string{"codigos_recuperacao": codes},
+	}, nil
+}
+
+// DesativarDoisFatores desliga o TOTP. Exige a senha e um código do
+// autenticador ou de recuperação.
+func (s *AuthService) DesativarDoisFatores(ctx context.Context, senha string, codigo string) (*models.APIResponse, error) {
+	user, err := s.usuarioAutenticado(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Verificação em duas etapas não está ativada",
+			Data:    nil,
+		}, nil
+	}
+	if err := s.conferirSenha(ctx, user, senha); err != nil {
+		return nil, err
+	}
+	if err := s.verificarSegundoFator(ctx, user, codigo); err != nil {
+		return nil, err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		users := repositories.NewUserRepository(tx)
+		if err := users.SetTOTP(ctx, user.ID, "", false); err != nil {
+			return err
+		}
+		if err := users.ReplaceRecoveryCodes(ctx, user.ID, nil); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActorFromContext(ctx), "user.totp_disable", "user", user.ID.String(),
+			map[string]bool{"totp_enabled": true}, map[string]bool{"totp_enabled": false})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.APIResponse{
+		Success: true,
+		Message: "Verificação em duas etapas desativada",
+		Data:    nil,
+	}, nil
+}
+
+// verificarSegundoFator aceita um código TOTP de 6 dígitos ou um código de
+// recuperação ainda não usado. Um código TOTP não vale duas vezes.
+func (s *AuthService) verificarSegundoFator(ctx context.Context, user *models.User, codigo string) error {
+	codigo = strings.TrimSpace(codigo)
+	if codigo == "" {
+		return ErrTOTPRequired
+	}
+
+	if len(codigo) == 6 && pinValido(codigo) {
+		secret, err := s.totpBox.Open(user.TOTPSecret)
+		if err != nil {
+			return err
+		}
+		step, ok := auth.VerifyTOTP(secret, codigo, time.Now(), totpSkew)
+		if !ok {
+			return ErrInvalidCredentials
+		}
+		fresh, err := s.users.UseTOTPStep(ctx, user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return ErrInvalidCredentials
+		}
+		return nil
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		used, err := repositories.NewUserRepository(tx).UseRecoveryCode(ctx, user.ID, hashToken(normalizarCodigoRecuperacao(codigo)))
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidCredentials
+		}
+		return audit.Record(tx, audit.ActorFromContext(ctx), "user.recovery_code_used", "user", user.ID.String(), nil, nil)
+	})
+}
+
+// usuarioAutenticado carrega o usuário do principal da requisição.
+func (s *AuthService) usuarioAutenticado(ctx context.Context) (*models.User, error) {
+	_, userID, err := principalUsuario(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, ErrForbidden
+	}
+	return user, nil
+}
+
+// conferirSenha confere a senha de novo em operações sensíveis. Erros contam
+// para o bloqueio do login.
+func (s *AuthService) conferirSenha(ctx context.Context, user *models.User, senha string) error {
+	if usuarioBloqueado(user, time.Now()) {
+		return ErrUserLocked
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(senha)); err != nil {
+		if err := s.users.RegisterFailedLogin(ctx, user.ID, maxFailedLogins, time.Now().Add(lockoutDuration)); err != nil {
+			return err
+		}
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// novoCodigoRecuperacao gera um código no formato xxxxx-xxxxx.
+func novoCodigoRecuperacao() (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	code := strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(token))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+func normalizarCodigoRecuperacao(codigo string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(codigo))
+}
@@ -3,6 +3,18 @@
 
 Write-Host "Iniciando o ambiente do Banco Digital..." -ForegroundColor Green
 
+# 0. Gerar os segredos locais no .env (fora do git) na primeira execução
+if (-not (Test-Path .env)) {
+    $rng = [System.Security.Cryptography.RandomNumberGenerator]::Create()
+    $jwt = New-Object byte[] 32; $rng.GetBytes($jwt)
+    $totp = New-Object byte[] 32; $rng.GetBytes($totp)
+    @(
+        "JWT_HMAC_SECRET=$([Convert]::ToBase64String($jwt))",
+        "TOTP_ENCRYPTION_KEY=$([Convert]::ToBase64String($totp))"
+    ) | Set-Content .env
+    Write-Host "Segredos locais gerados em .env" -ForegroundColor Yellow
+}
+
 # 1. Iniciar os serviços com Docker Compose
 docker-compose up -d --build
 
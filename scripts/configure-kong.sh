@@ -67,10 +67,15 @@ curl -i -X DELETE http://kong:8001/consumers/banco-digital-app
 curl -i -X POST http://kong:8001/consumers \
   --data username=banco-digital-app
 
-# Create JWT credentials for the consumer
+# Create JWT credentials for the consumer. The key is matched against the iss
+# claim and the secret comes from the .env file, never from the repository
+if [ -z "$JWT_HMAC_SECRET" ] || [ -z "$JWT_ISSUER" ]; then
+    echo "JWT_HMAC_SECRET and JWT_ISSUER are required" >&2
+    exit 1
+fi
 curl -i -X POST http://kong:8001/consumers/banco-digital-app/jwt \
-  --data key=37U4VjFAXAItqfppIrZcsfhMBm5hFoxL \
-  --data secret=your-256-bit-secret \
+  --data key="$JWT_ISSUER" \
+  --data secret="$JWT_HMAC_SECRET" \
   --data algorithm=HS256
 
 echo "Kong configuration completed!"
@@ -1,8 +1,15 @@
 const crypto = require('crypto');
 
-// JWT credentials from Kong
-const key = '37U4VjFAXAItqfppIrZcsfhMBm5hFoxL';
-const secret = 'your-256-bit-secret';
+// Segredo, emissor e audiência vêm do ambiente (o mesmo .env da API); nada
+// de segredo versionado
+const secret = process.env.JWT_HMAC_SECRET;
+const issuer = process.env.JWT_ISSUER || 'banco-digital';
+const audience = process.env.JWT_AUDIENCE || 'banco-digital-api';
+
+if (!secret) {
+  console.error('Defina JWT_HMAC_SECRET (veja .env.example)');
+  process.exit(1);
+}
 
 // Create JWT header
 const header = {
@@ -11,9 +18,14 @@ const header = {
 };
 
 // Create JWT payload
+const now = Math.floor(Date.now() / 1000);
 const payload = {
-  iss: key,
-  exp: Math.floor(Date.now() / 1000) + (365 * 24 * 60 * 60) // Expires in 1 year
+  iss: issuer, // Também é a chave da credencial JWT no Kong
+  aud: audience,
+  sub: process.argv[2] || 'cliente-demo', // Dono das contas criadas com o token
+  iat: now,
+  nbf: now,
+  exp: now + (60 * 60) // Expires in 1 hour
 };
 
 // Base64 encode
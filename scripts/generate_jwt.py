@@ -1,14 +1,24 @@
+import os
+import sys
 import jwt
 import time
 
-# Credenciais do Kong
-key = "vrIm0jvwvREuZlJVLmhLFso9S8SXJV1Z"
-secret = "your-256-bit-secret"
+# Segredo, emissor e audiência vêm do ambiente (o mesmo .env da API)
+secret = os.environ.get("JWT_HMAC_SECRET")
+if not secret:
+    sys.exit("Defina JWT_HMAC_SECRET (veja .env.example)")
+issuer = os.environ.get("JWT_ISSUER", "banco-digital")
+audience = os.environ.get("JWT_AUDIENCE", "banco-digital-api")
 
 # Criar payload
+now = int(time.time())
 payload = {
-    'iss': key,
-    'exp': int(time.time()) + 3600  # Token válido por 1 hora
+    'iss': issuer,  # Também é a chave da credencial JWT no Kong
+    'aud': audience,
+    'sub': sys.argv[1] if len(sys.argv) > 1 else 'cliente-demo',  # Dono das contas
+    'iat': now,
+    'nbf': now,
+    'exp': now + 3600  # Token válido por 1 hora
 }
 
 # Gerar token